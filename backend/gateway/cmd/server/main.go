@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"reviewpulse/gateway/internal/api"
@@ -18,40 +20,81 @@ func main() {
 	cfg := config.Load()
 
 	// --- Redis ---
-	redisSvc, err := services.NewRedisClient(cfg.RedisURL, cfg.RedisPassword, cfg.SessionTTLHrs)
+	redisConnOpts := services.RedisConnectOptions{
+		Mode:          cfg.RedisMode,
+		SentinelAddrs: cfg.RedisSentinelAddrs,
+		MasterName:    cfg.RedisMasterName,
+		ClusterAddrs:  cfg.RedisClusterAddrs,
+	}
+	redisPassword := cfg.RedisPassword
+	if cfg.RedisConnString != "" {
+		parsedOpts, parsedPassword, err := services.ParseConnString(cfg.RedisConnString)
+		if err != nil {
+			log.Fatalf("Invalid REDIS_CONN_STRING: %v", err)
+		}
+		redisConnOpts = parsedOpts
+		if parsedPassword != "" {
+			redisPassword = parsedPassword
+		}
+	}
+
+	redisSvc, err := services.NewRedisClient(cfg.RedisURL, redisPassword, cfg.SessionTTLHrs, redisConnOpts)
 	if err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
 	defer redisSvc.Close()
 	log.Println("Connected to Redis")
 
-	// --- RabbitMQ ---
-	queueSvc, err := services.NewQueueService(cfg.RabbitMQURL)
-	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+	// --- Queue ---
+	var queueSvc services.Queue
+	switch cfg.QueueBackend {
+	case "redis":
+		queueSvc = services.NewRedisQueue(redisSvc)
+		log.Println("Using Redis-backed queue")
+	default:
+		rabbitQueue, err := services.NewQueueService(cfg.RabbitMQURL)
+		if err != nil {
+			log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+		}
+		queueSvc = rabbitQueue
+		log.Println("Connected to RabbitMQ")
 	}
 	defer queueSvc.Close()
-	log.Println("Connected to RabbitMQ")
 
 	// --- Services ---
 	sessionSvc := services.NewSessionService(redisSvc)
-	openaiSvc := services.NewOpenAIService(cfg.OpenAIKey)
+	llmSvc := buildLLMProvider(cfg)
 
 	// --- Background worker: consume scrape results ---
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	results, err := queueSvc.ConsumeScrapeResults(ctx)
+	dedupSvc, err := services.NewURLDedupService(ctx, redisSvc, 100000, 0.01,
+		time.Duration(cfg.SessionTTLHrs)*time.Hour, time.Duration(cfg.SessionTTLHrs)*time.Hour)
 	if err != nil {
-		log.Fatalf("Failed to start scrape result consumer: %v", err)
+		log.Fatalf("Failed to initialize URL dedup service: %v", err)
 	}
+	go dedupSvc.RunRotation(ctx)
 
-	go processScrapeResults(ctx, results, sessionSvc, openaiSvc)
+	cacheSvc := services.NewAnalysisCache(ctx, redisSvc, 5000, 0, time.Duration(cfg.AnalysisCacheTTLSeconds)*time.Second)
+
+	jobsSvc := services.NewJobProcessor(redisSvc, queueSvc, 5, buildResultProcessor(sessionSvc, llmSvc, cacheSvc), nil,
+		func(ctx context.Context, token string) (time.Time, bool) {
+			deadline, err := sessionSvc.GetDeadline(ctx, token)
+			return deadline, err == nil
+		})
+	go func() {
+		if err := jobsSvc.Run(ctx); err != nil {
+			log.Fatalf("Failed to start scrape result consumer: %v", err)
+		}
+	}()
 
 	// --- HTTP Server ---
 	gin.SetMode(cfg.GinMode)
 	router := gin.Default()
-	api.SetupRoutes(router, sessionSvc, queueSvc, openaiSvc, redisSvc)
+	api.SetupRoutes(router, sessionSvc, queueSvc, llmSvc, redisSvc, dedupSvc, cacheSvc, jobsSvc,
+		time.Duration(cfg.AnalysisTimeoutSeconds)*time.Second, time.Duration(cfg.CacheStampedeWaitSeconds)*time.Second,
+		cfg.AdminAPIKey)
 
 	go func() {
 		addr := ":" + cfg.Port
@@ -69,86 +112,147 @@ func main() {
 	cancel()
 }
 
-// processScrapeResults listens for completed scrape jobs, runs OpenAI analysis,
-// and stores the results in Redis.
-func processScrapeResults(
-	ctx context.Context,
-	results <-chan models.ScrapeResult,
-	sessions *services.SessionService,
-	openai *services.OpenAIService,
-) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case result, ok := <-results:
-			if !ok {
-				return
+// buildLLMProvider constructs the LLM failover chain from cfg.LLMProviders,
+// in the configured order, skipping any provider whose API key isn't set.
+func buildLLMProvider(cfg *config.Config) services.LLMProvider {
+	var providers []services.LLMProvider
+	for _, name := range cfg.LLMProviders {
+		switch name {
+		case "claude":
+			if cfg.AnthropicKey == "" {
+				log.Printf("[startup] skipping claude provider: ANTHROPIC_API_KEY not set")
+				continue
 			}
-			handleScrapeResult(ctx, result, sessions, openai)
+			providers = append(providers, services.NewClaudeService(cfg.AnthropicKey))
+		case "openai":
+			providers = append(providers, services.NewOpenAIService(cfg.OpenAIKey))
+		default:
+			log.Printf("[startup] unknown LLM provider %q, skipping", name)
 		}
 	}
+	if len(providers) == 0 {
+		log.Fatal("FATAL: no usable LLM providers configured (check LLM_PROVIDERS and the matching API keys)")
+	}
+	return services.NewCompositeProvider(time.Duration(cfg.LLMTimeoutSeconds)*time.Second, providers...)
 }
 
-func handleScrapeResult(
-	ctx context.Context,
-	result models.ScrapeResult,
+// buildResultProcessor returns the per-result pipeline a JobProcessor
+// drives with retries: store reviews, run the configured LLM analysis,
+// store and cache the result. A returned error triggers a retry (and,
+// past the processor's attempt limit, a dead-letter entry) rather than
+// failing the session outright — except for a scrape error or an empty
+// review list, which mark the session as errored right away and still
+// return an error, so they are retried and dead-lettered as well.
+func buildResultProcessor(
 	sessions *services.SessionService,
-	openai *services.OpenAIService,
-) {
-	token := result.Token
-
-	if result.Error != "" {
-		log.Printf("[worker] scrape error for %s: %s", token, result.Error)
-		sessions.SetError(ctx, token, "Scraping failed: "+result.Error)
-		return
-	}
+	llm services.LLMProvider,
+	cache *services.AnalysisCache,
+) func(ctx context.Context, result models.ScrapeResult) error {
+	return func(ctx context.Context, result models.ScrapeResult) error {
+		token := result.Token
 
-	if len(result.Reviews) == 0 {
-		sessions.SetError(ctx, token, "No reviews found for this product")
-		return
-	}
+		// Fetched early (rather than only once analysis starts) so the
+		// single-flight cache lock this session's Analyze call may be
+		// holding can be released on the scrape-error and no-reviews paths
+		// as well as on success. The other failure paths don't release it;
+		// the lock then expires after singleFlightLockTTL.
+		session, sessErr := sessions.Get(ctx, token)
+		var cacheKey string
+		if cache != nil && sessErr == nil {
+			cacheKey = cache.Key(session.URL, session.OutputLanguage)
+		}
+		releaseCacheLock := func() {
+			if cacheKey != "" {
+				cache.Release(ctx, cacheKey)
+			}
+		}
 
-	// Store reviews
-	if err := sessions.StoreReviews(ctx, token, result.Reviews); err != nil {
-		log.Printf("[worker] store reviews error for %s: %v", token, err)
-		sessions.SetError(ctx, token, "Failed to store reviews")
-		return
-	}
+		if result.Error != "" {
+			sessions.SetError(ctx, token, "Scraping failed: "+result.Error)
+			releaseCacheLock()
+			return fmt.Errorf("scraping failed: %s", result.Error)
+		}
 
-	// Update status to analyzing
-	if err := sessions.UpdateStatus(ctx, token, "analyzing"); err != nil {
-		log.Printf("[worker] update status error for %s: %v", token, err)
-	}
+		if len(result.Reviews) == 0 {
+			sessions.SetError(ctx, token, "No reviews found for this product")
+			releaseCacheLock()
+			return fmt.Errorf("no reviews found for token %s", token)
+		}
 
-	// Get session for language preference
-	session, err := sessions.Get(ctx, token)
-	if err != nil {
-		log.Printf("[worker] get session error for %s: %v", token, err)
-		sessions.SetError(ctx, token, "Session expired during analysis")
-		return
-	}
+		if err := sessions.StoreReviews(ctx, token, result.Reviews); err != nil {
+			return fmt.Errorf("store reviews: %w", err)
+		}
 
-	// Run OpenAI analysis
-	analysis, err := openai.AnalyzeReviews(ctx, result.Reviews, session.OutputLanguage)
-	if err != nil {
-		log.Printf("[worker] openai error for %s: %v", token, err)
-		sessions.SetError(ctx, token, "AI analysis failed")
-		return
-	}
+		if err := sessions.UpdateStatus(ctx, token, "analyzing"); err != nil {
+			log.Printf("[worker] update status error for %s: %v", token, err)
+		}
+
+		if sessErr != nil {
+			return fmt.Errorf("get session: %w", sessErr)
+		}
+
+		analysis, err := streamAnalysis(ctx, sessions, llm, token, result.Reviews, session.OutputLanguage)
+		if err != nil {
+			return fmt.Errorf("llm analysis: %w", err)
+		}
+
+		if err := sessions.StoreAnalysis(ctx, token, analysis); err != nil {
+			return fmt.Errorf("store analysis: %w", err)
+		}
+
+		// Warm the analysis cache so the next request for this URL skips
+		// scraping and the LLM call entirely, and release the single-flight
+		// lock so anyone waiting on WaitReady picks up the fresh result.
+		if cache != nil {
+			if err := cache.Set(ctx, cacheKey, analysis); err != nil {
+				log.Printf("[worker] cache analysis error for %s: %v", token, err)
+			}
+			releaseCacheLock()
+		}
+
+		if err := sessions.UpdateStatus(ctx, token, "complete"); err != nil {
+			log.Printf("[worker] complete status error for %s: %v", token, err)
+		}
 
-	// Store results
-	if err := sessions.StoreAnalysis(ctx, token, analysis); err != nil {
-		log.Printf("[worker] store analysis error for %s: %v", token, err)
-		sessions.SetError(ctx, token, "Failed to store analysis")
-		return
+		log.Printf("[worker] analysis complete for session %s (%d reviews, lang=%s)",
+			token, len(result.Reviews), session.OutputLanguage)
+		return nil
 	}
+}
 
-	// Mark complete
-	if err := sessions.UpdateStatus(ctx, token, "complete"); err != nil {
-		log.Printf("[worker] complete status error for %s: %v", token, err)
+// streamAnalysis runs llm.AnalyzeStream, forwarding each progress chunk to
+// subscribed StreamProgress connections via sessions.PublishChunk as it
+// arrives, so the frontend sees the LLM's output incrementally rather than
+// only once it's complete, then returns the final parsed result.
+func streamAnalysis(
+	ctx context.Context,
+	sessions *services.SessionService,
+	llm services.LLMProvider,
+	token string,
+	reviews []models.Review,
+	language string,
+) (*models.AnalysisResult, error) {
+	events, results, err := llm.AnalyzeStream(ctx, reviews, language)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Printf("[worker] analysis complete for session %s (%d reviews, lang=%s)",
-		token, len(result.Reviews), session.OutputLanguage)
+	for events != nil || results != nil {
+		select {
+		case evt, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if evt.Chunk != "" {
+				sessions.PublishChunk(ctx, token, evt.Chunk)
+			}
+		case result, ok := <-results:
+			if !ok {
+				return nil, fmt.Errorf("llm stream closed without a result")
+			}
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("llm stream ended without a result")
 }
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListDLQ returns jobs that permanently failed after retrying and were
+// moved to the dead-letter queue.
+func (h *Handler) ListDLQ(c *gin.Context) {
+	if h.jobs == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "job processor not configured"})
+		return
+	}
+	entries, err := h.jobs.ListDLQ(c.Request.Context(), 100)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list dead-lettered jobs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// ReplayDLQ re-queues a dead-lettered job identified by its session token
+// for another attempt.
+func (h *Handler) ReplayDLQ(c *gin.Context) {
+	if h.jobs == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "job processor not configured"})
+		return
+	}
+	token := c.Param("token")
+	if err := h.jobs.ReplayDLQ(c.Request.Context(), token); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"status": "replaying"})
+}
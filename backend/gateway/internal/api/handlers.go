@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"reviewpulse/gateway/internal/models"
@@ -12,26 +13,56 @@ import (
 
 // Handler holds dependencies for HTTP handlers.
 type Handler struct {
-	sessions *services.SessionService
-	queue    *services.QueueService
-	openai   *services.OpenAIService
+	sessions        *services.SessionService
+	queue           services.Queue
+	llm             services.LLMProvider
+	dedup           *services.URLDedupService
+	cache           *services.AnalysisCache
+	jobs            *services.JobProcessor
+	analysisTimeout time.Duration
+
+	// cacheStampedeWait bounds how long a request that lost the analysis
+	// cache's single-flight race waits for the winner's result before
+	// falling through to scrape the URL itself.
+	cacheStampedeWait time.Duration
 }
 
 // NewHandler creates a Handler with all required services.
 func NewHandler(
 	sessions *services.SessionService,
-	queue *services.QueueService,
-	openai *services.OpenAIService,
+	queue services.Queue,
+	llm services.LLMProvider,
+	dedup *services.URLDedupService,
+	cache *services.AnalysisCache,
+	jobs *services.JobProcessor,
+	analysisTimeout time.Duration,
+	cacheStampedeWait time.Duration,
 ) *Handler {
-	return &Handler{sessions: sessions, queue: queue, openai: openai}
+	return &Handler{
+		sessions:          sessions,
+		queue:             queue,
+		llm:               llm,
+		dedup:             dedup,
+		cache:             cache,
+		jobs:              jobs,
+		analysisTimeout:   analysisTimeout,
+		cacheStampedeWait: cacheStampedeWait,
+	}
 }
 
 // HealthCheck returns 200 if the gateway and its dependencies are healthy.
 func (h *Handler) HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":   "ok",
-		"service":  "reviewpulse-gateway",
-	})
+	resp := gin.H{
+		"status":  "ok",
+		"service": "reviewpulse-gateway",
+	}
+	if h.dedup != nil {
+		resp["url_dedup"] = h.dedup.Stats()
+	}
+	if h.cache != nil {
+		resp["analysis_cache"] = h.cache.Stats()
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // CreateSession creates a new empty session and returns the token.
@@ -127,14 +158,81 @@ func (h *Handler) Analyze(c *gin.Context) {
 
 	ctx := c.Request.Context()
 
+	// Serve straight from the analysis cache when this URL + language was
+	// analyzed recently, skipping both the scrape job and the LLM call.
+	// force_refresh bypasses this entirely for callers who know the cached
+	// result is stale.
+	var cacheKey string
+	var holdingCacheLock bool
+	// releaseCacheLock is called on every path below that returns without
+	// ever reaching PublishScrapeJob (which is what's actually responsible
+	// for the lock once a real scrape+analyze is under way) — otherwise an
+	// acquired-but-unused lock sits around for the full singleFlightLockTTL,
+	// stalling every other request for this URL in WaitReady for nothing.
+	releaseCacheLock := func() {
+		if holdingCacheLock {
+			h.cache.Release(ctx, cacheKey)
+			holdingCacheLock = false
+		}
+	}
+
+	if h.cache != nil && !req.ForceRefresh {
+		cacheKey = h.cache.Key(req.URL, lang)
+
+		if cached, ok := h.cache.Get(ctx, cacheKey); ok {
+			if h.serveCachedAnalysis(c, req.URL, platform, lang, cached) {
+				return
+			}
+		} else if acquired, err := h.cache.Acquire(ctx, cacheKey); err != nil {
+			log.Printf("[handler] cache lock acquire: %v", err)
+		} else if acquired {
+			holdingCacheLock = true
+		} else {
+			// Another request for this same URL is already scraping and
+			// analyzing it — wait briefly for that result instead of
+			// starting a redundant scrape + LLM pass ourselves.
+			if cached, ok := h.cache.WaitReady(ctx, cacheKey, h.cacheStampedeWait); ok {
+				if h.serveCachedAnalysis(c, req.URL, platform, lang, cached) {
+					return
+				}
+			}
+			// Didn't show up in time (or serving it failed) — fall through
+			// and scrape it ourselves.
+		}
+	}
+
 	// Create session
 	session, err := h.sessions.Create(ctx, req.URL, platform, lang)
 	if err != nil {
 		log.Printf("[handler] create session: %v", err)
+		releaseCacheLock()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
 		return
 	}
 
+	// If this URL was almost certainly analyzed already in the current
+	// dedup window, hand back the existing session instead of re-scraping.
+	if h.dedup != nil {
+		existingToken, found, err := h.dedup.CheckAndReserve(ctx, req.URL, session.Token)
+		if err != nil {
+			log.Printf("[handler] dedup check: %v", err)
+		} else if found {
+			existing, err := h.sessions.Get(ctx, existingToken)
+			if err == nil {
+				releaseCacheLock()
+				c.JSON(http.StatusOK, gin.H{
+					"token":    existing.Token,
+					"status":   existing.Status,
+					"platform": existing.Platform,
+					"language": existing.OutputLanguage,
+					"deduped":  true,
+				})
+				return
+			}
+			// The mapped session has since expired; fall through and scrape.
+		}
+	}
+
 	// Update status to scraping
 	if err := h.sessions.UpdateStatus(ctx, session.Token, "scraping"); err != nil {
 		log.Printf("[handler] update status: %v", err)
@@ -149,10 +247,15 @@ func (h *Handler) Analyze(c *gin.Context) {
 	if err := h.queue.PublishScrapeJob(ctx, job); err != nil {
 		log.Printf("[handler] publish scrape job: %v", err)
 		h.sessions.SetError(ctx, session.Token, "failed to enqueue scraping job")
+		releaseCacheLock()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start analysis"})
 		return
 	}
 
+	if err := h.sessions.SetDeadline(ctx, session.Token, time.Now().Add(h.analysisTimeout)); err != nil {
+		log.Printf("[handler] set deadline: %v", err)
+	}
+
 	c.JSON(http.StatusAccepted, gin.H{
 		"token":    session.Token,
 		"status":   "scraping",
@@ -161,6 +264,35 @@ func (h *Handler) Analyze(c *gin.Context) {
 	})
 }
 
+// serveCachedAnalysis creates a session pre-populated with a cached
+// analysis result and responds as if it had just completed, for both a
+// direct cache hit and a single-flight waiter that caught the winning
+// request's result. Returns false (leaving the response unwritten) if the
+// session couldn't be created, so the caller falls through to a fresh scrape.
+func (h *Handler) serveCachedAnalysis(c *gin.Context, url, platform, lang string, cached *models.AnalysisResult) bool {
+	ctx := c.Request.Context()
+
+	session, err := h.sessions.Create(ctx, url, platform, lang)
+	if err != nil {
+		log.Printf("[handler] create session for cache hit: %v", err)
+		return false
+	}
+	if err := h.sessions.StoreAnalysis(ctx, session.Token, cached); err != nil {
+		log.Printf("[handler] store cached analysis: %v", err)
+	}
+	if err := h.sessions.UpdateStatus(ctx, session.Token, "complete"); err != nil {
+		log.Printf("[handler] update status: %v", err)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"token":    session.Token,
+		"status":   "complete",
+		"platform": platform,
+		"language": lang,
+		"cached":   true,
+	})
+	return true
+}
+
 // detectPlatform identifies the e-commerce platform from a URL.
 func detectPlatform(url string) string {
 	lower := strings.ToLower(url)
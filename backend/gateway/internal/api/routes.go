@@ -1,6 +1,8 @@
 package api
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"reviewpulse/gateway/internal/middleware"
 	"reviewpulse/gateway/internal/services"
@@ -10,21 +12,61 @@ import (
 func SetupRoutes(
 	r *gin.Engine,
 	sessions *services.SessionService,
-	queue *services.QueueService,
-	openaiSvc *services.OpenAIService,
+	queue services.Queue,
+	llmSvc services.LLMProvider,
 	redisSvc *services.RedisClient,
+	dedupSvc *services.URLDedupService,
+	cacheSvc *services.AnalysisCache,
+	jobsSvc *services.JobProcessor,
+	analysisTimeout time.Duration,
+	cacheStampedeWait time.Duration,
+	adminAPIKey string,
 ) {
-	handler := NewHandler(sessions, queue, openaiSvc)
+	handler := NewHandler(sessions, queue, llmSvc, dedupSvc, cacheSvc, jobsSvc, analysisTimeout, cacheStampedeWait)
 
 	r.Use(middleware.CORS())
 
 	api := r.Group("/api")
-	api.Use(middleware.RateLimit(redisSvc, 30, 60)) // 30 requests per 60 seconds per IP
+	api.Use(middleware.RateLimit(redisSvc, middleware.RateLimitConfig{
+		Algorithm:   middleware.SlidingWindow,
+		MaxRequests: 30,
+		Window:      60 * time.Second,
+		KeyFunc:     middleware.ByClientIP,
+		FailOpen:    true,
+	}))
 
 	api.GET("/health", handler.HealthCheck)
 	api.POST("/session", handler.CreateSession)
 	api.POST("/session/:token/heartbeat", handler.Heartbeat)
 	api.GET("/session/:token", handler.GetSession)
-	api.POST("/analyze", handler.Analyze)
-	api.GET("/analyze/:token/stream", handler.StreamProgress)
+
+	// /analyze triggers a scrape + LLM pass, so it gets a tighter
+	// per-client sliding window than the default group limit above.
+	api.POST("/analyze", middleware.RateLimit(redisSvc, middleware.RateLimitConfig{
+		Algorithm:   middleware.SlidingWindow,
+		MaxRequests: 10,
+		Window:      60 * time.Second,
+		KeyFunc:     middleware.ByClientIP,
+		FailOpen:    true,
+	}), handler.Analyze)
+
+	// /stream is a long-lived SSE connection per session token, so it's
+	// limited by token via a token bucket (smooths reconnect bursts
+	// better than a hard per-window cap) rather than by IP.
+	api.GET("/analyze/:token/stream", middleware.RateLimit(redisSvc, middleware.RateLimitConfig{
+		Algorithm:  middleware.TokenBucket,
+		RatePerSec: 1,
+		BurstSize:  5,
+		KeyFunc:    middleware.BySessionToken,
+		FailOpen:   true,
+	}), handler.StreamProgress)
+
+	// Admin routes expose other users' scraped review content (ListDLQ)
+	// and let a caller force reprocessing of an arbitrary session
+	// (ReplayDLQ), so they sit behind AdminAuth in addition to the blanket
+	// per-IP limiter above.
+	admin := api.Group("/admin")
+	admin.Use(middleware.AdminAuth(adminAPIKey))
+	admin.GET("/dlq", handler.ListDLQ)
+	admin.POST("/dlq/:token/replay", handler.ReplayDLQ)
 }
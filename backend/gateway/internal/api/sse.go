@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -11,66 +12,120 @@ import (
 	"reviewpulse/gateway/internal/models"
 )
 
-// StreamProgress sends Server-Sent Events with session status updates.
-// The client connects and receives events until the session reaches a
-// terminal state (complete or error) or the connection is closed.
+// StreamProgress sends Server-Sent Events with session status updates. It
+// subscribes to the session's Redis Pub/Sub channel and forwards each
+// status change as it's published by SessionService, rather than polling —
+// this is what lets a scrape worker and the SSE-serving instance live on
+// different gateway replicas. The client receives events until the session
+// reaches a terminal state (complete or error) or the connection is closed.
 func (h *Handler) StreamProgress(c *gin.Context) {
 	token := c.Param("token")
 	ctx := c.Request.Context()
 
-	// Verify session exists
-	exists, err := h.sessions.Exists(ctx, token)
-	if err != nil || !exists {
+	// Confirm the session exists before doing anything else. The actual
+	// status snapshot is taken after Subscribe below, not here — a status
+	// transition that lands between an existence check here and opening
+	// the subscription would otherwise be missed entirely.
+	if exists, err := h.sessions.Exists(ctx, token); err != nil || !exists {
 		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
 		return
 	}
 
-	// Set SSE headers
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
 	c.Writer.Header().Set("Connection", "keep-alive")
 	c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 	c.Writer.Flush()
 
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
+	// Deriving the loop's context from the session's stored deadline (set
+	// by Handler.Analyze) rather than just the request context means a
+	// client that reconnects mid-analysis sees the same time-remaining
+	// instead of getting a fresh timeout budget.
+	if deadline, err := h.sessions.GetDeadline(ctx, token); err == nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	// Subscribing before taking the status snapshot below means a
+	// transition that fires in between is observed as a Pub/Sub event
+	// instead of falling into a gap where it's too late for the snapshot
+	// and too early for the subscription. The existing lastStatus dedup
+	// check below makes a duplicate/racing event for the same status a
+	// harmless no-op.
+	pubsub := h.sessions.Subscribe(ctx, token)
+	defer pubsub.Close()
+	events := pubsub.Channel()
+
+	session, err := h.sessions.Get(ctx, token)
+	if err != nil {
+		// Headers are already flushed at this point, so there's no status
+		// code left to send — just close the stream.
+		log.Printf("[sse] get session %s after subscribe: %v", token, err)
+		return
+	}
+
+	lastStatus := session.Status
+	sendSSE(c, "status", &models.ProgressEvent{
+		Status:  session.Status,
+		Message: statusMessage(session),
+	})
+	if session.Status == "complete" || session.Status == "error" {
+		return
+	}
 
-	lastStatus := ""
+	// Now that status changes are pushed via Pub/Sub, the ticker is only
+	// for keep-alive comments between them.
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			session, err := h.sessions.Get(ctx, token)
-			if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				h.sessions.SetError(context.Background(), token, "analysis timed out")
 				sendSSE(c, "error", &models.ProgressEvent{
 					Status:  "error",
-					Message: "session expired or not found",
+					Message: "analysis timed out",
 				})
+			}
+			return
+		case msg, ok := <-events:
+			if !ok {
 				return
 			}
+			var evt models.SessionEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &evt); err != nil {
+				log.Printf("[sse] unmarshal event for %s: %v", token, err)
+				continue
+			}
 
-			// Only send an event when the status changes.
-			if session.Status == lastStatus {
-				// Send a heartbeat comment to keep the connection alive.
-				fmt.Fprintf(c.Writer, ": heartbeat\n\n")
-				c.Writer.Flush()
+			if evt.Chunk != "" {
+				sendSSE(c, "chunk", &models.ProgressEvent{
+					Status: "analyzing",
+					Chunk:  evt.Chunk,
+				})
 				continue
 			}
-			lastStatus = session.Status
 
-			event := &models.ProgressEvent{
-				Status:  session.Status,
-				Message: statusMessage(session),
+			updated := evt.Session
+			if updated == nil || updated.Status == lastStatus {
+				continue
 			}
+			lastStatus = updated.Status
 
-			sendSSE(c, "status", event)
+			sendSSE(c, "status", &models.ProgressEvent{
+				Status:  updated.Status,
+				Message: statusMessage(updated),
+			})
 
 			// Terminal states — close the stream.
-			if session.Status == "complete" || session.Status == "error" {
+			if updated.Status == "complete" || updated.Status == "error" {
 				return
 			}
+		case <-keepAlive.C:
+			fmt.Fprintf(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
 		}
 	}
 }
@@ -1,31 +1,177 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"reviewpulse/gateway/internal/services"
 )
 
-// RateLimit returns a middleware that limits requests per IP using Redis.
-// maxRequests is the maximum number of requests allowed within windowSecs.
-func RateLimit(redis *services.RedisClient, maxRequests int64, windowSecs int) gin.HandlerFunc {
-	window := time.Duration(windowSecs) * time.Second
+// RateLimitAlgorithm selects which Redis-backed algorithm RateLimit enforces.
+type RateLimitAlgorithm string
+
+const (
+	SlidingWindow RateLimitAlgorithm = "sliding_window"
+	TokenBucket   RateLimitAlgorithm = "token_bucket"
+)
+
+// RateLimitKeyFunc extracts the identity a request should be limited by.
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// ByClientIP keys by the request's client IP.
+func ByClientIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// BySessionToken keys by the :token URL param, falling back to client IP
+// on routes where it's absent.
+func BySessionToken(c *gin.Context) string {
+	if token := c.Param("token"); token != "" {
+		return token
+	}
+	return c.ClientIP()
+}
+
+// ByAPIKey keys by the X-API-Key request header, falling back to client IP.
+func ByAPIKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	return c.ClientIP()
+}
+
+// RateLimitConfig configures one RateLimit middleware instance, so
+// different routes (/analyze, /stream, ...) can each pick their own
+// algorithm, limit, and key.
+type RateLimitConfig struct {
+	Algorithm RateLimitAlgorithm
+
+	// MaxRequests and Window apply to SlidingWindow.
+	MaxRequests int64
+	Window      time.Duration
+
+	// RatePerSec and BurstSize apply to TokenBucket.
+	RatePerSec float64
+	BurstSize  int64
+
+	KeyFunc RateLimitKeyFunc
+
+	// FailOpen allows requests through (instead of rejecting them) when
+	// Redis can't be reached to evaluate the limit.
+	FailOpen bool
+}
+
+func (cfg RateLimitConfig) limit() int64 {
+	if cfg.Algorithm == TokenBucket {
+		return cfg.BurstSize
+	}
+	return cfg.MaxRequests
+}
+
+// slidingWindowScript trims entries older than the window, checks the
+// remaining count against the limit, and — only if under it — records
+// this request, all atomically so concurrent requests can't both slip
+// through at the window boundary.
+const slidingWindowScript = `
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local member = ARGV[4]
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local count = redis.call('ZCARD', key)
+
+local allowed = 0
+if count < limit then
+  redis.call('ZADD', key, now, member)
+  redis.call('PEXPIRE', key, window)
+  count = count + 1
+  allowed = 1
+end
+
+return {count, allowed}
+`
+
+// tokenBucketScript refills the bucket proportionally to elapsed time
+// since the last refill, then takes one token if available.
+const tokenBucketScript = `
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+
+local bucket = redis.call('HMGET', key, 'tokens', 'last_refill_ms')
+local tokens = tonumber(bucket[1])
+local last = tonumber(bucket[2])
+if tokens == nil then
+  tokens = burst
+  last = now
+end
+
+local elapsed = math.max(0, now - last)
+tokens = math.min(burst, tokens + elapsed * rate / 1000)
+
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call('HMSET', key, 'tokens', tostring(tokens), 'last_refill_ms', now)
+redis.call('PEXPIRE', key, math.ceil((burst / rate) * 1000) * 2)
+
+return {tostring(tokens), allowed}
+`
+
+// RateLimit returns a Gin middleware that enforces cfg's limit per
+// cfg.KeyFunc(c), via a Lua script so the read-check-write cycle for
+// either algorithm is atomic in Redis.
+func RateLimit(redis *services.RedisClient, cfg RateLimitConfig) gin.HandlerFunc {
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = ByClientIP
+	}
+
+	// Keys are scoped by algorithm and limit, so limiters with different
+	// settings (e.g. the /api group and /analyze) keep separate counters
+	// instead of both counting into the same per-client key.
+	scope := fmt.Sprintf("%s:%d:%d", cfg.Algorithm, cfg.limit(), cfg.Window.Milliseconds())
 
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		key := fmt.Sprintf("ratelimit:%s", ip)
+		key := fmt.Sprintf("ratelimit:%s:%s", scope, cfg.KeyFunc(c))
+
+		var allowed bool
+		var remaining, resetSecs int64
+		var err error
+
+		if cfg.Algorithm == TokenBucket {
+			allowed, remaining, resetSecs, err = evalTokenBucket(c, redis, key, cfg)
+		} else {
+			allowed, remaining, resetSecs, err = evalSlidingWindow(c, redis, key, cfg)
+		}
 
-		count, err := redis.Incr(c.Request.Context(), key, window)
 		if err != nil {
-			// If Redis is down, allow the request but log the error.
-			c.Next()
+			if cfg.FailOpen {
+				c.Next()
+				return
+			}
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "rate limiter unavailable"})
+			c.Abort()
 			return
 		}
 
-		if count > maxRequests {
+		c.Header("X-RateLimit-Limit", strconv.FormatInt(cfg.limit(), 10))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(resetSecs, 10))
+
+		if !allowed {
+			c.Header("Retry-After", strconv.FormatInt(resetSecs, 10))
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error": "rate limit exceeded, please try again later",
 			})
@@ -36,3 +182,49 @@ func RateLimit(redis *services.RedisClient, maxRequests int64, windowSecs int) g
 		c.Next()
 	}
 }
+
+func evalSlidingWindow(c *gin.Context, redis *services.RedisClient, key string, cfg RateLimitConfig) (allowed bool, remaining, resetSecs int64, err error) {
+	now := time.Now().UnixMilli()
+	windowMs := cfg.Window.Milliseconds()
+	member := fmt.Sprintf("%d-%s", now, uuid.New().String())
+
+	res, err := redis.Eval(c.Request.Context(), slidingWindowScript, []string{key}, now, windowMs, cfg.MaxRequests, member)
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	arr, ok := res.([]interface{})
+	if !ok || len(arr) != 2 {
+		return false, 0, 0, errors.New("unexpected sliding window script result")
+	}
+	count, _ := arr[0].(int64)
+	allowedFlag, _ := arr[1].(int64)
+
+	remaining = cfg.MaxRequests - count
+	if remaining < 0 {
+		remaining = 0
+	}
+	resetSecs = int64(cfg.Window.Seconds())
+	return allowedFlag == 1, remaining, resetSecs, nil
+}
+
+func evalTokenBucket(c *gin.Context, redis *services.RedisClient, key string, cfg RateLimitConfig) (allowed bool, remaining, resetSecs int64, err error) {
+	now := time.Now().UnixMilli()
+
+	res, err := redis.Eval(c.Request.Context(), tokenBucketScript, []string{key}, now, cfg.RatePerSec, cfg.BurstSize)
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	arr, ok := res.([]interface{})
+	if !ok || len(arr) != 2 {
+		return false, 0, 0, errors.New("unexpected token bucket script result")
+	}
+	tokensLeft, _ := arr[0].(string)
+	allowedFlag, _ := arr[1].(int64)
+
+	tokensFloat, _ := strconv.ParseFloat(tokensLeft, 64)
+	remaining = int64(math.Floor(tokensFloat))
+	resetSecs = int64(math.Ceil(float64(cfg.BurstSize) / cfg.RatePerSec))
+	return allowedFlag == 1, remaining, resetSecs, nil
+}
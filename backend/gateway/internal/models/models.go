@@ -42,6 +42,10 @@ type AnalyzeRequest struct {
 	URL            string `json:"url" binding:"required"`
 	OutputLanguage string `json:"output_language"` // en | vi | es | ja
 	SessionToken   string `json:"session_token"`
+
+	// ForceRefresh skips the analysis cache even on a hit, for callers that
+	// know the product's reviews have changed and want a fresh scrape.
+	ForceRefresh bool `json:"force_refresh"`
 }
 
 // ScrapeJob is published to RabbitMQ for the Python worker.
@@ -62,6 +66,20 @@ type ScrapeResult struct {
 type ProgressEvent struct {
 	Status  string `json:"status"`
 	Message string `json:"message,omitempty"`
+	// Chunk carries a partial-token slice of the LLM's streamed output,
+	// populated while Status is "analyzing" during AnalyzeStream.
+	Chunk string `json:"chunk,omitempty"`
+}
+
+// SessionEvent is what SessionService actually publishes on a session's
+// Pub/Sub channel: either a full session-status snapshot (the usual case,
+// published by UpdateStatus/SetError/StoreAnalysis) or a streamed LLM
+// output chunk (published by PublishChunk while AnalyzeStream runs), never
+// both at once. Keeping them in one envelope lets every subscriber read a
+// single channel instead of needing to guess which shape arrived.
+type SessionEvent struct {
+	Session *Session `json:"session,omitempty"`
+	Chunk   string   `json:"chunk,omitempty"`
 }
 
 // SupportedLanguages maps language codes to full names for OpenAI prompts.
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"reviewpulse/gateway/internal/models"
+)
+
+const (
+	cacheInvalidateChannel = "cache:analysis:invalidate"
+
+	// singleFlightLockTTL bounds how long a cache key's single-flight lock
+	// is held: long enough to cover a scrape + LLM pass, but not so long
+	// that a crashed holder (one that never reaches Set or Release) wedges
+	// other requests for the full session TTL.
+	singleFlightLockTTL = 5 * time.Minute
+)
+
+// trackingQueryParams are query keys stripped during normalization because
+// they vary per click/referrer without identifying a different product.
+// Entries are lowercase because normalizeForCache lowercases the whole URL
+// before the query is parsed.
+var trackingQueryParams = map[string]bool{
+	"ref": true, "ref_": true, "tag": true, "linkcode": true,
+	"gclid": true, "fbclid": true, "msclkid": true,
+	"spm": true, "scm": true, "utm_source": true, "utm_medium": true,
+	"utm_campaign": true, "utm_term": true, "utm_content": true,
+}
+
+// amazonASINPattern matches the /dp/, /gp/product/, or /product/ path
+// shapes Amazon uses, all of which identify the same item by a trailing
+// 10-character ASIN.
+var amazonASINPattern = regexp.MustCompile(`/(?:dp|gp/product|product)/([A-Za-z0-9]{10})`)
+
+// AnalysisCache is a two-tier cache for models.AnalysisResult: an
+// in-process LRU in front of a Redis tier, inspired by the
+// store-then-supplier fallback chains this service already uses for
+// scraping. A cache hit lets Handler.Analyze skip both the scrape job and
+// the LLM call entirely.
+//
+// Keys are derived from the normalized product URL and output language —
+// see Key. Writes go through both tiers; Redis-level invalidation is
+// announced over Pub/Sub so every gateway instance's LRU stays consistent.
+type AnalysisCache struct {
+	redis *RedisClient
+	ttl   time.Duration
+
+	// instanceID tags every Pub/Sub announcement this instance makes, so
+	// subscribeInvalidations can tell its own writes apart from another
+	// replica's and skip evicting the entry it just populated.
+	instanceID string
+
+	mu       sync.Mutex
+	order    *list.List
+	items    map[string]*list.Element
+	curBytes int
+	maxCount int
+	maxBytes int
+
+	hits   int64
+	misses int64
+}
+
+type cacheEntry struct {
+	key    string
+	result *models.AnalysisResult
+	size   int
+}
+
+// NewAnalysisCache creates a cache bounded by maxCount entries and
+// maxBytes of (JSON-encoded) result data, whichever limit is hit first.
+// It subscribes to cross-instance invalidation events in the background.
+func NewAnalysisCache(ctx context.Context, redis *RedisClient, maxCount, maxBytes int, ttl time.Duration) *AnalysisCache {
+	c := &AnalysisCache{
+		redis:      redis,
+		ttl:        ttl,
+		instanceID: uuid.New().String(),
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		maxCount:   maxCount,
+		maxBytes:   maxBytes,
+	}
+	go c.subscribeInvalidations(ctx)
+	return c
+}
+
+// Key derives the cache key for a product URL + output language.
+func (c *AnalysisCache) Key(url, language string) string {
+	normalized := normalizeForCache(url)
+	sum := sha256.Sum256([]byte(normalized + "|" + language))
+	return "analysis:" + hex.EncodeToString(sum[:])
+}
+
+// normalizeForCache reduces cosmetic differences between URLs that point at
+// the same product page: host casing, tracking query params (utm_*, ref,
+// gclid, ...), and, for Amazon, the several path shapes that all resolve to
+// the same ASIN. Falls back to a plain lowercase/trim if the URL doesn't
+// parse, so a malformed input still gets a stable (if less deduplicated) key.
+func normalizeForCache(rawURL string) string {
+	trimmed := strings.ToLower(strings.TrimSpace(rawURL))
+
+	parsed, err := url.Parse(trimmed)
+	if err != nil || parsed.Host == "" {
+		return trimmed
+	}
+
+	parsed.Fragment = ""
+
+	query := parsed.Query()
+	for key := range query {
+		if strings.HasPrefix(key, "utm_") || trackingQueryParams[key] {
+			query.Del(key)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+
+	if asin := amazonASIN(parsed.Path); asin != "" {
+		parsed.Path = "/dp/" + strings.ToUpper(asin)
+	}
+
+	return parsed.String()
+}
+
+// amazonASIN extracts the 10-character ASIN from an Amazon product path, if
+// present.
+func amazonASIN(path string) string {
+	match := amazonASINPattern.FindStringSubmatch(path)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// Get checks the in-process LRU first, then Redis. A Redis hit is
+// promoted back into the LRU.
+func (c *AnalysisCache) Get(ctx context.Context, key string) (*models.AnalysisResult, bool) {
+	if result, ok := c.getLocal(key); ok {
+		atomic.AddInt64(&c.hits, 1)
+		return result, true
+	}
+
+	var result models.AnalysisResult
+	if err := c.redis.GetJSON(ctx, key, &result); err != nil {
+		atomic.AddInt64(&c.misses, 1)
+		return nil, false
+	}
+
+	atomic.AddInt64(&c.hits, 1)
+	c.setLocal(key, &result)
+	return &result, true
+}
+
+// Set writes through both tiers and announces the write so other gateway
+// instances can warm (or at least not serve a stale copy of) their LRU.
+func (c *AnalysisCache) Set(ctx context.Context, key string, result *models.AnalysisResult) error {
+	c.setLocal(key, result)
+	if err := c.redis.SetJSONWithTTL(ctx, key, result, c.ttl); err != nil {
+		return err
+	}
+	return c.announce(ctx, key)
+}
+
+// Acquire takes a best-effort single-flight lock for key, so that of several
+// concurrent requests for the same (now-expired or never-cached) URL, only
+// one actually scrapes and runs the LLM. Callers that don't acquire it
+// should call WaitReady instead of doing the same expensive work themselves.
+func (c *AnalysisCache) Acquire(ctx context.Context, key string) (bool, error) {
+	return c.redis.AcquireLock(ctx, key+":lock", singleFlightLockTTL)
+}
+
+// Release drops the single-flight lock for key. It's safe to call even if
+// this instance never held the lock — releasing an absent key is a no-op —
+// so callers can call it unconditionally once a job settles (success or
+// permanent failure) without tracking whether they were the original holder.
+func (c *AnalysisCache) Release(ctx context.Context, key string) error {
+	return c.redis.ReleaseLock(ctx, key+":lock")
+}
+
+// WaitReady is the losing side of the single-flight race: it waits up to
+// `wait` for the lock holder to announce a fresh result via Set, and returns
+// it directly instead of the caller redoing the scrape + LLM pass. Returns
+// ok=false on timeout or cancellation, in which case the caller should fall
+// through to computing its own result.
+func (c *AnalysisCache) WaitReady(ctx context.Context, key string, wait time.Duration) (*models.AnalysisResult, bool) {
+	// Subscribing before the failed Acquire that led here and this call
+	// aren't atomic, so the winner could have already called Set/announce
+	// in that gap — in which case its announcement fired before anyone
+	// was listening and the select loop below would never see it. Once
+	// subscribed, an immediate Get check catches that case directly,
+	// instead of always burning the full wait and redoing the work.
+	pubsub := c.redis.Subscribe(ctx, cacheInvalidateChannel)
+	defer pubsub.Close()
+	ch := pubsub.Channel()
+
+	if result, ok := c.Get(ctx, key); ok {
+		return result, true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-timer.C:
+			return nil, false
+		case msg, ok := <-ch:
+			if !ok {
+				return nil, false
+			}
+			var evt cacheInvalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &evt); err != nil {
+				continue
+			}
+			if evt.Key != key {
+				continue
+			}
+			return c.Get(ctx, key)
+		}
+	}
+}
+
+// InvalidateByURL drops the cached result for a URL + language pair from
+// both tiers, and tells other instances to drop it too.
+func (c *AnalysisCache) InvalidateByURL(ctx context.Context, url, language string) error {
+	key := c.Key(url, language)
+	c.evictLocal(key)
+	if err := c.redis.Delete(ctx, key); err != nil {
+		return err
+	}
+	return c.announce(ctx, key)
+}
+
+// InvalidateAll clears the local LRU and tells other instances to do the
+// same. It does not scan and delete every Redis key — entries still expire
+// via TTL.
+func (c *AnalysisCache) InvalidateAll(ctx context.Context) error {
+	c.mu.Lock()
+	c.order = list.New()
+	c.items = make(map[string]*list.Element)
+	c.curBytes = 0
+	c.mu.Unlock()
+	return c.announce(ctx, "*")
+}
+
+// CacheStats is a point-in-time snapshot for observability (e.g. /api/health).
+type CacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+}
+
+// Stats returns cumulative hit/miss counters and the current LRU size.
+func (c *AnalysisCache) Stats() CacheStats {
+	c.mu.Lock()
+	entries := len(c.items)
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:    atomic.LoadInt64(&c.hits),
+		Misses:  atomic.LoadInt64(&c.misses),
+		Entries: entries,
+	}
+}
+
+func (c *AnalysisCache) getLocal(key string) (*models.AnalysisResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).result, true
+}
+
+func (c *AnalysisCache) setLocal(key string, result *models.AnalysisResult) {
+	data, err := json.Marshal(result)
+	size := len(data)
+	if err != nil {
+		size = 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.curBytes -= elem.Value.(*cacheEntry).size
+		c.order.MoveToFront(elem)
+		elem.Value = &cacheEntry{key: key, result: result, size: size}
+		c.curBytes += size
+	} else {
+		elem := c.order.PushFront(&cacheEntry{key: key, result: result, size: size})
+		c.items[key] = elem
+		c.curBytes += size
+	}
+
+	for (c.maxCount > 0 && len(c.items) > c.maxCount) || (c.maxBytes > 0 && c.curBytes > c.maxBytes) {
+		back := c.order.Back()
+		if back == nil {
+			break
+		}
+		c.removeElement(back)
+	}
+}
+
+func (c *AnalysisCache) evictLocal(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// removeElement must be called with c.mu held.
+func (c *AnalysisCache) removeElement(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.order.Remove(elem)
+	delete(c.items, entry.key)
+	c.curBytes -= entry.size
+}
+
+// cacheInvalidation is what's actually published on cacheInvalidateChannel:
+// the affected key (or "*" for InvalidateAll) tagged with the publishing
+// instance's ID, so subscribeInvalidations can skip the writer's own
+// announcement of the entry it just populated.
+type cacheInvalidation struct {
+	Origin string `json:"origin"`
+	Key    string `json:"key"`
+}
+
+func (c *AnalysisCache) announce(ctx context.Context, key string) error {
+	data, err := json.Marshal(cacheInvalidation{Origin: c.instanceID, Key: key})
+	if err != nil {
+		return err
+	}
+	return c.redis.Publish(ctx, cacheInvalidateChannel, string(data))
+}
+
+// subscribeInvalidations evicts local entries as other gateway instances
+// write or invalidate them, so this instance's LRU never serves a copy
+// that's been superseded elsewhere. It skips announcements this same
+// instance made — Set already updated the local LRU directly, so evicting
+// it again here would just force an unnecessary Redis round-trip on the
+// very next local Get.
+func (c *AnalysisCache) subscribeInvalidations(ctx context.Context) {
+	pubsub := c.redis.Subscribe(ctx, cacheInvalidateChannel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var evt cacheInvalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &evt); err != nil {
+				continue
+			}
+			if evt.Origin == c.instanceID {
+				continue
+			}
+			if evt.Key == "*" {
+				c.mu.Lock()
+				c.order = list.New()
+				c.items = make(map[string]*list.Element)
+				c.curBytes = 0
+				c.mu.Unlock()
+				continue
+			}
+			c.evictLocal(evt.Key)
+		}
+	}
+}
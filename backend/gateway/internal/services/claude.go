@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -19,8 +20,9 @@ const (
 	claudeModel      = "claude-sonnet-4-20250514"
 )
 
-// ClaudeService wraps the Anthropic Claude API client.
-// The API key is loaded from the environment and NEVER exposed in responses or logs.
+// ClaudeService wraps the Anthropic Claude API client and implements
+// LLMProvider. The API key is loaded from the environment and NEVER
+// exposed in responses or logs.
 type ClaudeService struct {
 	apiKey string
 	client *http.Client
@@ -41,6 +43,7 @@ type claudeRequest struct {
 	System      string          `json:"system"`
 	Messages    []claudeMessage `json:"messages"`
 	Temperature float64         `json:"temperature"`
+	Stream      bool            `json:"stream,omitempty"`
 }
 
 // claudeMessage represents a single message in a Claude conversation.
@@ -61,45 +64,20 @@ type claudeResponse struct {
 	} `json:"error"`
 }
 
-// AnalyzeReviews sends reviews to Claude and returns structured analysis.
-func (c *ClaudeService) AnalyzeReviews(ctx context.Context, reviews []models.Review, language string) (*models.AnalysisResult, error) {
-	langName, ok := models.SupportedLanguages[language]
-	if !ok {
-		langName = "English"
-		language = "en"
-	}
-
-	// Build review text block
-	var sb strings.Builder
-	for i, r := range reviews {
-		sb.WriteString(fmt.Sprintf("Review %d (Rating: %.1f/5): %s\n", i+1, r.Rating, r.Text))
-	}
-
-	systemPrompt := fmt.Sprintf(`You are a product review analyst. Respond ENTIRELY in %s.
-
-Analyze the customer reviews provided and return a JSON object with exactly this structure:
-{
-  "summary": "A 2-3 sentence overall summary of customer opinions",
-  "pros": ["pro 1", "pro 2", ...],
-  "cons": ["con 1", "con 2", ...],
-  "sentiment": {"positive": <count>, "neutral": <count>, "negative": <count>},
-  "keywords": ["keyword1", "keyword2", ...]
+// claudeStreamEvent is the subset of Anthropic's SSE event fields this
+// client cares about: text deltas and the terminal error event.
+type claudeStreamEvent struct {
+	Type  string `json:"type"`
+	Delta *struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
 }
 
-Rules:
-- List 3-8 pros and 3-8 cons, derived from actual review content.
-- sentiment counts must add up to the total number of reviews.
-- keywords: top 5-10 most frequently mentioned product aspects.
-- Respond ONLY with valid JSON. No markdown, no explanation.`, langName)
-
-	reqBody := claudeRequest{
-		Model:       claudeModel,
-		MaxTokens:   4096,
-		System:      systemPrompt,
-		Messages:    []claudeMessage{{Role: "user", Content: sb.String()}},
-		Temperature: 0.3,
-	}
-
+func (c *ClaudeService) newRequest(ctx context.Context, reqBody claudeRequest) (*http.Response, error) {
 	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -118,7 +96,38 @@ Rules:
 	if err != nil {
 		// IMPORTANT: Never log the API key. Only log the error message.
 		log.Printf("[claude] API call failed: %v", err)
-		return nil, fmt.Errorf("claude analysis failed: %w", err)
+		return nil, &ProviderError{Provider: "claude", Err: fmt.Errorf("claude analysis failed: %w", err)}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		log.Printf("[claude] API returned status %d: %s", resp.StatusCode, string(respBody))
+		return nil, &ProviderError{
+			Provider:   "claude",
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("claude API error (status %d)", resp.StatusCode),
+		}
+	}
+
+	return resp, nil
+}
+
+// Analyze sends reviews to Claude and returns structured analysis.
+func (c *ClaudeService) Analyze(ctx context.Context, reviews []models.Review, language string) (*models.AnalysisResult, error) {
+	systemPrompt, language := buildAnalysisSystemPrompt(language)
+
+	reqBody := claudeRequest{
+		Model:       claudeModel,
+		MaxTokens:   4096,
+		System:      systemPrompt,
+		Messages:    []claudeMessage{{Role: "user", Content: buildReviewPrompt(reviews)}},
+		Temperature: 0.3,
+	}
+
+	resp, err := c.newRequest(ctx, reqBody)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -127,11 +136,6 @@ Rules:
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("[claude] API returned status %d: %s", resp.StatusCode, string(respBody))
-		return nil, fmt.Errorf("claude API error (status %d)", resp.StatusCode)
-	}
-
 	var claudeResp claudeResponse
 	if err := json.Unmarshal(respBody, &claudeResp); err != nil {
 		log.Printf("[claude] failed to parse API response: %v", err)
@@ -141,12 +145,10 @@ Rules:
 	if claudeResp.Error != nil {
 		return nil, fmt.Errorf("claude error: %s", claudeResp.Error.Message)
 	}
-
 	if len(claudeResp.Content) == 0 {
 		return nil, fmt.Errorf("claude returned no content")
 	}
 
-	// Extract text from the first content block
 	raw := ""
 	for _, block := range claudeResp.Content {
 		if block.Type == "text" {
@@ -158,18 +160,67 @@ Rules:
 		return nil, fmt.Errorf("claude returned no text content")
 	}
 
-	// Strip markdown code fences if present
-	raw = strings.TrimPrefix(raw, "```json")
-	raw = strings.TrimPrefix(raw, "```")
-	raw = strings.TrimSuffix(raw, "```")
-	raw = strings.TrimSpace(raw)
+	return parseAnalysisJSON(raw, language)
+}
 
-	var result models.AnalysisResult
-	if err := json.Unmarshal([]byte(raw), &result); err != nil {
-		log.Printf("[claude] failed to parse response JSON: %v â€” raw: %s", err, raw)
-		return nil, fmt.Errorf("failed to parse AI response: %w", err)
+// AnalyzeStream does the same as Analyze, but consumes Claude's SSE stream
+// (stream: true) and forwards each text delta as a ProgressEvent so the
+// frontend sees incremental output rather than a single "complete" flip.
+func (c *ClaudeService) AnalyzeStream(ctx context.Context, reviews []models.Review, language string) (<-chan models.ProgressEvent, <-chan *models.AnalysisResult, error) {
+	systemPrompt, language := buildAnalysisSystemPrompt(language)
+
+	reqBody := claudeRequest{
+		Model:       claudeModel,
+		MaxTokens:   4096,
+		System:      systemPrompt,
+		Messages:    []claudeMessage{{Role: "user", Content: buildReviewPrompt(reviews)}},
+		Temperature: 0.3,
+		Stream:      true,
 	}
 
-	result.Language = language
-	return &result, nil
+	resp, err := c.newRequest(ctx, reqBody)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events := make(chan models.ProgressEvent, 16)
+	results := make(chan *models.AnalysisResult, 1)
+
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+		defer close(results)
+
+		var raw strings.Builder
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			var evt claudeStreamEvent
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &evt); err != nil {
+				continue
+			}
+			if evt.Error != nil {
+				log.Printf("[claude] stream error: %s", evt.Error.Message)
+				return
+			}
+			if evt.Type == "content_block_delta" && evt.Delta != nil && evt.Delta.Text != "" {
+				raw.WriteString(evt.Delta.Text)
+				events <- models.ProgressEvent{Status: "analyzing", Chunk: evt.Delta.Text}
+			}
+		}
+
+		result, err := parseAnalysisJSON(raw.String(), language)
+		if err != nil {
+			log.Printf("[claude] failed to parse streamed response: %v", err)
+			return
+		}
+		results <- result
+	}()
+
+	return events, results, nil
 }
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"reviewpulse/gateway/internal/models"
+)
+
+// CompositeProvider tries a list of LLMProviders in order, falling back to
+// the next one on a retryable failure (429, 5xx, or a context timeout),
+// backing off with jitter between attempts so a struggling provider isn't
+// hammered.
+type CompositeProvider struct {
+	providers []LLMProvider
+	timeout   time.Duration
+}
+
+// NewCompositeProvider builds a failover chain over providers, tried in
+// the given order, each bounded by timeout.
+func NewCompositeProvider(timeout time.Duration, providers ...LLMProvider) *CompositeProvider {
+	return &CompositeProvider{providers: providers, timeout: timeout}
+}
+
+// Analyze tries each provider in order until one succeeds.
+func (c *CompositeProvider) Analyze(ctx context.Context, reviews []models.Review, language string) (*models.AnalysisResult, error) {
+	var lastErr error
+	for i, provider := range c.providers {
+		if i > 0 {
+			backoff(i)
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		result, err := provider.Analyze(attemptCtx, reviews, language)
+		cancel()
+		if err == nil {
+			return result, nil
+		}
+
+		lastErr = err
+		if !isRetryable(err) || i == len(c.providers)-1 {
+			break
+		}
+		log.Printf("[llm] provider %d failed (%v), failing over to provider %d", i, err, i+1)
+	}
+	return nil, fmt.Errorf("all LLM providers failed: %w", lastErr)
+}
+
+// AnalyzeStream tries each provider in order until one's stream starts
+// successfully. Once a stream has started, CompositeProvider doesn't
+// switch providers mid-stream — only the initial request is retried.
+func (c *CompositeProvider) AnalyzeStream(ctx context.Context, reviews []models.Review, language string) (<-chan models.ProgressEvent, <-chan *models.AnalysisResult, error) {
+	var lastErr error
+	for i, provider := range c.providers {
+		if i > 0 {
+			backoff(i)
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		events, results, err := provider.AnalyzeStream(attemptCtx, reviews, language)
+		if err == nil {
+			// The attempt's timeout covers the whole stream, so it's only
+			// cancelled once the provider has delivered (or given up on) its result.
+			out := make(chan *models.AnalysisResult, 1)
+			go func() {
+				defer cancel()
+				defer close(out)
+				if result, ok := <-results; ok {
+					out <- result
+				}
+			}()
+			return events, out, nil
+		}
+		cancel()
+
+		lastErr = err
+		if !isRetryable(err) || i == len(c.providers)-1 {
+			break
+		}
+		log.Printf("[llm] provider %d stream failed (%v), failing over to provider %d", i, err, i+1)
+	}
+	return nil, nil, fmt.Errorf("all LLM providers failed: %w", lastErr)
+}
+
+// isRetryable reports whether a failure is worth failing over for, as
+// opposed to a permanent error (bad request, auth failure, parse error).
+func isRetryable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var perr *ProviderError
+	if errors.As(err, &perr) {
+		return perr.Retryable()
+	}
+	return false
+}
+
+// backoff sleeps with exponential growth (capped) plus full jitter before
+// the attempt-th failover, so a rate-limited provider gets some breathing
+// room instead of being hit again immediately.
+func backoff(attempt int) {
+	base := time.Duration(1<<uint(attempt)) * 100 * time.Millisecond
+	if base > 2*time.Second {
+		base = 2 * time.Second
+	}
+	time.Sleep(time.Duration(rand.Int63n(int64(base) + 1)))
+}
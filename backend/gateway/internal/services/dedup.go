@@ -0,0 +1,255 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+const (
+	bloomActiveKey   = "bloom:analyzed_urls"
+	bloomPreviousKey = "bloom:analyzed_urls:previous"
+	bloomRotateLock  = "bloom:analyzed_urls:rotate_lock"
+	dedupURLPrefix   = "dedup:url:"
+
+	// bloomPersistInterval bounds how stale a persisted filter can get
+	// between rotations: CheckAndReserve only marks the active filter
+	// dirty, it never writes to Redis itself, so a periodic tick is what
+	// actually flushes it.
+	bloomPersistInterval = 30 * time.Second
+)
+
+// URLDedupService tracks product URLs that have very likely already been
+// analyzed in the current session-TTL window, so Handler.Analyze can
+// short-circuit to an existing session instead of enqueuing a new scrape.
+//
+// Membership is tracked with two rotating Bloom filters (active + previous)
+// so entries age out instead of growing the filter forever; a probable hit
+// is confirmed against the canonical url -> token mapping stored in Redis,
+// which also carries the small false-positive rate.
+type URLDedupService struct {
+	redis *RedisClient
+
+	mu       sync.RWMutex
+	active   *bloom.BloomFilter
+	previous *bloom.BloomFilter
+	// dirty marks that active has been written to (CheckAndReserve) since
+	// it was last persisted, so the periodic flush in RunRotation has
+	// something to do without re-serializing an unchanged filter every tick.
+	dirty bool
+
+	nItems      uint
+	fpRate      float64
+	rotateEvery time.Duration
+	mappingTTL  time.Duration
+}
+
+// NewURLDedupService creates a dedup service sized for nItems expected
+// entries per rotation window at the given false-positive rate. The
+// url -> token mapping is kept for mappingTTL, which should match the
+// session TTL so a dedup hit never outlives the session it points at. It
+// loads any previously persisted filters from Redis, or starts empty.
+func NewURLDedupService(ctx context.Context, redis *RedisClient, nItems uint, fpRate float64, rotateEvery, mappingTTL time.Duration) (*URLDedupService, error) {
+	s := &URLDedupService{
+		redis:       redis,
+		nItems:      nItems,
+		fpRate:      fpRate,
+		rotateEvery: rotateEvery,
+		mappingTTL:  mappingTTL,
+	}
+
+	active, err := s.loadOrNew(ctx, bloomActiveKey)
+	if err != nil {
+		return nil, fmt.Errorf("load active bloom filter: %w", err)
+	}
+	previous, err := s.loadOrNew(ctx, bloomPreviousKey)
+	if err != nil {
+		return nil, fmt.Errorf("load previous bloom filter: %w", err)
+	}
+	s.active = active
+	s.previous = previous
+
+	return s, nil
+}
+
+func (s *URLDedupService) loadOrNew(ctx context.Context, key string) (*bloom.BloomFilter, error) {
+	data, err := s.redis.GetBytes(ctx, key)
+	if err != nil {
+		return bloom.NewWithEstimates(s.nItems, s.fpRate), nil
+	}
+	f := &bloom.BloomFilter{}
+	if _, err := f.ReadFrom(bytes.NewReader(data)); err != nil {
+		log.Printf("[dedup] failed to decode bloom filter %s, starting fresh: %v", key, err)
+		return bloom.NewWithEstimates(s.nItems, s.fpRate), nil
+	}
+	return f, nil
+}
+
+// serializeFilter encodes f into a standalone byte slice. Callers that need
+// a consistent snapshot of a filter still being mutated concurrently must
+// call this while holding s.mu, so the encode can't interleave with an Add.
+func serializeFilter(f *bloom.BloomFilter) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *URLDedupService) persistBytes(ctx context.Context, key string, data []byte) error {
+	return s.redis.SetBytes(ctx, key, data, 0)
+}
+
+// CheckAndReserve reports whether url was probably already analyzed. On a
+// probable hit it confirms against the canonical url -> token mapping; if
+// that mapping is also present it returns the existing token. On a miss (or
+// a false positive where the mapping has already expired) it reserves the
+// URL for token and returns found=false.
+func (s *URLDedupService) CheckAndReserve(ctx context.Context, url, token string) (existingToken string, found bool, err error) {
+	key := []byte(normalizeURLForDedup(url))
+
+	s.mu.RLock()
+	probablyPresent := s.active.Test(key) || s.previous.Test(key)
+	s.mu.RUnlock()
+
+	if probablyPresent {
+		existing, err := s.redis.GetString(ctx, dedupMappingKey(url))
+		if err == nil && existing != "" {
+			return existing, true, nil
+		}
+		// False positive (or the mapping already expired) — fall through
+		// and treat this as a fresh URL.
+	}
+
+	s.mu.Lock()
+	s.active.Add(key)
+	s.dirty = true
+	s.mu.Unlock()
+
+	// Persisting the whole filter synchronously on every miss would make
+	// every dedup-missed request pay for a Redis round-trip it doesn't
+	// need; marking it dirty here and letting RunRotation's periodic
+	// flush (or the next rotation) pick it up keeps this request's path
+	// to just the in-memory Add above.
+	if err := s.redis.SetString(ctx, dedupMappingKey(url), token, s.mappingTTL); err != nil {
+		log.Printf("[dedup] failed to store url->token mapping: %v", err)
+	}
+
+	return "", false, nil
+}
+
+// DedupStats is a point-in-time snapshot for observability (e.g. /api/health).
+type DedupStats struct {
+	EstimatedCount    uint32  `json:"estimated_count"`
+	FalsePositiveRate float64 `json:"false_positive_rate"`
+}
+
+// Stats returns the active filter's estimated cardinality and configured
+// false-positive rate.
+func (s *URLDedupService) Stats() DedupStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return DedupStats{
+		EstimatedCount:    s.active.ApproximatedSize(),
+		FalsePositiveRate: s.fpRate,
+	}
+}
+
+// RunRotation swaps the active filter into "previous" and starts a fresh
+// active filter every rotateEvery, so URLs analyzed more than two rotation
+// windows ago age out of the dedup check. A Redis lock ensures only one
+// gateway instance performs the swap when several are running. Between
+// rotations, it also periodically flushes the active filter to Redis if
+// CheckAndReserve has marked it dirty, since that's the only place it's
+// written to outside of a rotation.
+func (s *URLDedupService) RunRotation(ctx context.Context) {
+	rotateTicker := time.NewTicker(s.rotateEvery)
+	defer rotateTicker.Stop()
+
+	persistTicker := time.NewTicker(bloomPersistInterval)
+	defer persistTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-rotateTicker.C:
+			s.rotate(ctx)
+		case <-persistTicker.C:
+			s.persistActiveIfDirty(ctx)
+		}
+	}
+}
+
+// persistActiveIfDirty flushes the active filter to Redis if it's changed
+// since the last flush. The filter is serialized while s.mu is held so the
+// encode can't race with a concurrent CheckAndReserve's Add, then the
+// (comparatively slow) Redis write happens with the lock already released.
+func (s *URLDedupService) persistActiveIfDirty(ctx context.Context) {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return
+	}
+	data, err := serializeFilter(s.active)
+	if err == nil {
+		s.dirty = false
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[dedup] failed to serialize active bloom filter: %v", err)
+		return
+	}
+	if err := s.persistBytes(ctx, bloomActiveKey, data); err != nil {
+		log.Printf("[dedup] failed to persist active bloom filter: %v", err)
+	}
+}
+
+func (s *URLDedupService) rotate(ctx context.Context) {
+	acquired, err := s.redis.AcquireLock(ctx, bloomRotateLock, 30*time.Second)
+	if err != nil || !acquired {
+		return
+	}
+	defer s.redis.ReleaseLock(ctx, bloomRotateLock)
+
+	s.mu.Lock()
+	previous := s.active
+	s.active = bloom.NewWithEstimates(s.nItems, s.fpRate)
+	s.previous = previous
+	s.dirty = false
+
+	previousData, previousErr := serializeFilter(previous)
+	activeData, activeErr := serializeFilter(s.active)
+	s.mu.Unlock()
+
+	if previousErr != nil {
+		log.Printf("[dedup] failed to serialize previous bloom filter: %v", previousErr)
+	} else if err := s.persistBytes(ctx, bloomPreviousKey, previousData); err != nil {
+		log.Printf("[dedup] failed to persist previous bloom filter: %v", err)
+	}
+	if activeErr != nil {
+		log.Printf("[dedup] failed to serialize active bloom filter: %v", activeErr)
+	} else if err := s.persistBytes(ctx, bloomActiveKey, activeData); err != nil {
+		log.Printf("[dedup] failed to persist active bloom filter: %v", err)
+	}
+	log.Println("[dedup] rotated bloom filters")
+}
+
+func dedupMappingKey(url string) string {
+	return dedupURLPrefix + normalizeURLForDedup(url)
+}
+
+// normalizeURLForDedup hashes the URL so dedup keys have a fixed length
+// regardless of how long the analyzed product URL is.
+func normalizeURLForDedup(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"reviewpulse/gateway/internal/models"
+)
+
+const (
+	dlqKey                = "scrape_results.dlq"
+	defaultMaxJobAttempts = 5
+	jobLeaseTTL           = 2 * time.Minute
+	jobLeaseRenewInterval = jobLeaseTTL / 2
+)
+
+// JobState is the per-token retry bookkeeping JobProcessor keeps in Redis,
+// so a crash mid-pipeline (between StoreReviews and StoreAnalysis) doesn't
+// lose track of how many times a job has already been retried — or the
+// payload itself, which is persisted here (not just held in the handle
+// goroutine's memory) so a crashed attempt can still be found and replayed
+// via the DLQ rather than silently disappearing.
+type JobState struct {
+	Result    models.ScrapeResult `json:"result"`
+	Attempts  int                 `json:"attempts"`
+	LastError string              `json:"last_error,omitempty"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// DLQEntry is a permanently failed job parked for manual inspection or replay.
+type DLQEntry struct {
+	Token    string              `json:"token"`
+	Result   models.ScrapeResult `json:"result"`
+	State    JobState            `json:"state"`
+	FailedAt time.Time           `json:"failed_at"`
+}
+
+func jobStateKey(token string) string {
+	return fmt.Sprintf("session:%s:job", token)
+}
+
+func jobLeaseKey(token string) string {
+	return fmt.Sprintf("session:%s:job:lease", token)
+}
+
+// JobProcessor wraps Queue.ConsumeScrapeResults with manual retry handling:
+// each result is leased via a Redis SETNX lock so multiple gateway
+// replicas consuming the same queue don't double-process it, retried with
+// exponential backoff + jitter on failure, and moved to a dead-letter
+// queue after maxAttempts.
+type JobProcessor struct {
+	redis       *RedisClient
+	queue       Queue
+	process     func(ctx context.Context, result models.ScrapeResult) error
+	maxAttempts int
+
+	// onTerminal, if set, is called once a token's job either succeeds or
+	// is sent to the DLQ, for hooking in side effects that only matter
+	// once a job is settled.
+	onTerminal func(token string)
+
+	// deadline, if set, looks up the time a token's scrape+analyze
+	// pipeline must finish by (e.g. SessionService.GetDeadline), so handle
+	// can bound each process call to that budget instead of letting a
+	// hung or slow-retrying job run under nothing but the Run-level
+	// background context until the process crashes or is shut down.
+	deadline func(ctx context.Context, token string) (time.Time, bool)
+}
+
+// NewJobProcessor builds a JobProcessor that hands each consumed
+// ScrapeResult to process. maxAttempts <= 0 falls back to a default of 5.
+// deadline may be nil, in which case process calls are only bounded by
+// ctx.
+func NewJobProcessor(
+	redis *RedisClient,
+	queue Queue,
+	maxAttempts int,
+	process func(ctx context.Context, result models.ScrapeResult) error,
+	onTerminal func(token string),
+	deadline func(ctx context.Context, token string) (time.Time, bool),
+) *JobProcessor {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxJobAttempts
+	}
+	return &JobProcessor{
+		redis:       redis,
+		queue:       queue,
+		process:     process,
+		maxAttempts: maxAttempts,
+		onTerminal:  onTerminal,
+		deadline:    deadline,
+	}
+}
+
+// Run consumes results from the queue until ctx is cancelled, applying the
+// lease + retry + DLQ policy to each one. Each result is handled in its
+// own goroutine so a slow retry backoff for one token doesn't stall others.
+func (p *JobProcessor) Run(ctx context.Context) error {
+	results, err := p.queue.ConsumeScrapeResults(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case delivery, ok := <-results:
+			if !ok {
+				return nil
+			}
+			go p.handle(ctx, delivery)
+		}
+	}
+}
+
+// handle drives the retry loop for a single delivery, acking it only once
+// processing either succeeds or the job is sent to the DLQ — never right
+// after it was handed to us, so a crash mid-pipeline leaves it on the
+// backend's processing list/unacked set instead of losing it. RabbitMQ
+// redelivers an unacked message; RedisQueue leaves the entry on its
+// processing list and does not move it back onto the results list.
+func (p *JobProcessor) handle(ctx context.Context, delivery ScrapeResultDelivery) {
+	result := delivery.Result
+	token := result.Token
+
+	acquired, err := p.redis.AcquireLock(ctx, jobLeaseKey(token), jobLeaseTTL)
+	if err != nil {
+		log.Printf("[job] lease check for %s: %v", token, err)
+		return
+	}
+	if !acquired {
+		// Another replica already owns this token's job, so this delivery
+		// is a duplicate of one already being processed — ack it rather
+		// than leaving it parked on the processing list/unacked set forever.
+		delivery.Ack()
+		return
+	}
+	defer p.redis.ReleaseLock(ctx, jobLeaseKey(token))
+
+	// The lease's TTL has to be shorter than a worst-case stuck processing
+	// run so a crashed holder doesn't wedge the token forever, but a
+	// legitimate run (retries with backoff, each attempt potentially
+	// hitting the LLM timeout) can easily take longer than that TTL.
+	// Renewing periodically keeps the lease alive for as long as this
+	// goroutine is actually making progress, without raising the TTL
+	// itself (which would slow down recovery from a real crash).
+	renewCtx, stopRenew := context.WithCancel(ctx)
+	defer stopRenew()
+	go p.renewLease(renewCtx, token)
+
+	state := p.loadState(ctx, token)
+	// Persist the payload before the first attempt, not just on failure,
+	// so even a crash on attempt 1 leaves a durable record to recover.
+	state.Result = result
+	p.saveState(ctx, token, state)
+
+	// procCtx bounds every process call below to the session's own
+	// analysis deadline (if one was set via SessionService.SetDeadline),
+	// not just the Run-level background ctx — otherwise a hung or
+	// slow-retrying attempt runs indefinitely instead of actually being
+	// cancelled once its time budget is up.
+	procCtx := ctx
+	if p.deadline != nil {
+		if deadline, ok := p.deadline(ctx, token); ok {
+			var cancel context.CancelFunc
+			procCtx, cancel = context.WithDeadline(ctx, deadline)
+			defer cancel()
+		}
+	}
+
+	for {
+		err := p.process(procCtx, result)
+		if err == nil {
+			p.clearState(ctx, token)
+			p.notifyTerminal(token)
+			delivery.Ack()
+			return
+		}
+
+		state.Attempts++
+		state.LastError = err.Error()
+		state.UpdatedAt = time.Now().UTC()
+		p.saveState(ctx, token, state)
+
+		// Once procCtx's deadline has passed, every further attempt would
+		// fail immediately too — stop retrying now instead of burning the
+		// rest of the attempt budget on backoff sleeps that can't help.
+		if state.Attempts >= p.maxAttempts || procCtx.Err() != nil {
+			log.Printf("[job] %s failed permanently after %d attempts: %v", token, state.Attempts, err)
+			p.sendToDLQ(ctx, token, result, state)
+			p.notifyTerminal(token)
+			delivery.Ack()
+			return
+		}
+
+		log.Printf("[job] %s attempt %d failed, retrying: %v", token, state.Attempts, err)
+		backoff(state.Attempts)
+	}
+}
+
+// renewLease periodically extends a token's job lease for as long as ctx is
+// live, so a long retry loop (several LLM-timeout attempts plus backoff can
+// easily exceed jobLeaseTTL) doesn't have its lease expire out from under
+// it and let a second replica's AcquireLock succeed on the same token.
+func (p *JobProcessor) renewLease(ctx context.Context, token string) {
+	ticker := time.NewTicker(jobLeaseRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.redis.RenewLock(ctx, jobLeaseKey(token), jobLeaseTTL); err != nil {
+				log.Printf("[job] renew lease for %s: %v", token, err)
+			}
+		}
+	}
+}
+
+func (p *JobProcessor) notifyTerminal(token string) {
+	if p.onTerminal != nil {
+		p.onTerminal(token)
+	}
+}
+
+func (p *JobProcessor) loadState(ctx context.Context, token string) JobState {
+	var state JobState
+	_ = p.redis.GetJSON(ctx, jobStateKey(token), &state)
+	return state
+}
+
+func (p *JobProcessor) saveState(ctx context.Context, token string, state JobState) {
+	if err := p.redis.SetJSON(ctx, jobStateKey(token), state); err != nil {
+		log.Printf("[job] save state for %s: %v", token, err)
+	}
+}
+
+func (p *JobProcessor) clearState(ctx context.Context, token string) {
+	_ = p.redis.Delete(ctx, jobStateKey(token))
+}
+
+func (p *JobProcessor) sendToDLQ(ctx context.Context, token string, result models.ScrapeResult, state JobState) {
+	entry := DLQEntry{Token: token, Result: result, State: state, FailedAt: time.Now().UTC()}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[job] marshal dlq entry for %s: %v", token, err)
+		return
+	}
+	if err := p.redis.ListPush(ctx, dlqKey, data); err != nil {
+		log.Printf("[job] push dlq entry for %s: %v", token, err)
+	}
+	p.clearState(ctx, token)
+}
+
+// ListDLQ returns up to limit dead-lettered entries, most recently failed first.
+func (p *JobProcessor) ListDLQ(ctx context.Context, limit int64) ([]DLQEntry, error) {
+	raw, err := p.redis.ListRange(ctx, dlqKey, 0, limit-1)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]DLQEntry, 0, len(raw))
+	for _, data := range raw {
+		var entry DLQEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			log.Printf("[job] unmarshal dlq entry: %v", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ReplayDLQ removes the dead-lettered entry for token, if any, and re-runs
+// it through process with a fresh attempt count.
+func (p *JobProcessor) ReplayDLQ(ctx context.Context, token string) error {
+	raw, err := p.redis.ListRange(ctx, dlqKey, 0, -1)
+	if err != nil {
+		return err
+	}
+
+	for _, data := range raw {
+		var entry DLQEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			continue
+		}
+		if entry.Token != token {
+			continue
+		}
+		if err := p.redis.ListRem(ctx, dlqKey, data); err != nil {
+			return err
+		}
+		// Nothing to ack here — the entry has already been removed from
+		// the DLQ above, not redelivered by the queue. The replay runs on a
+		// background context: ctx is typically the admin HTTP request's,
+		// which is cancelled as soon as the handler responds.
+		go p.handle(context.Background(), ScrapeResultDelivery{Result: entry.Result, Ack: func() {}})
+		return nil
+	}
+	return fmt.Errorf("no dead-lettered job found for token %s", token)
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"reviewpulse/gateway/internal/models"
+)
+
+// LLMProvider is implemented by every review-analysis backend (Claude,
+// OpenAI, and CompositeProvider which fails over between them), so
+// Handler and the scrape-result worker don't need to know which one
+// they're talking to.
+type LLMProvider interface {
+	// Analyze sends reviews to the model and returns the parsed, structured
+	// analysis in one shot.
+	Analyze(ctx context.Context, reviews []models.Review, language string) (*models.AnalysisResult, error)
+	// AnalyzeStream does the same, but forwards partial-token progress on
+	// the returned event channel as the model streams its response. The
+	// event channel closes once the result channel has a value. If the
+	// stream fails after it has started, the provider logs the error and
+	// closes the result channel without sending a value.
+	AnalyzeStream(ctx context.Context, reviews []models.Review, language string) (<-chan models.ProgressEvent, <-chan *models.AnalysisResult, error)
+}
+
+// ProviderError wraps an LLM provider's HTTP-level failure with the status
+// code, so CompositeProvider can decide whether it's worth retrying or
+// failing over without string-matching error messages.
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Provider, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether this failure is the kind CompositeProvider
+// should fail over on: rate limiting or a transient server-side error.
+func (e *ProviderError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// buildAnalysisSystemPrompt is the prompt shared by every provider: it's
+// the part of the request that doesn't depend on which model answers it.
+func buildAnalysisSystemPrompt(language string) (systemPrompt, resolvedLanguage string) {
+	langName, ok := models.SupportedLanguages[language]
+	if !ok {
+		langName = "English"
+		language = "en"
+	}
+
+	systemPrompt = fmt.Sprintf(`You are a product review analyst. Respond ENTIRELY in %s.
+
+Analyze the customer reviews provided and return a JSON object with exactly this structure:
+{
+  "summary": "A 2-3 sentence overall summary of customer opinions",
+  "pros": ["pro 1", "pro 2", ...],
+  "cons": ["con 1", "con 2", ...],
+  "sentiment": {"positive": <count>, "neutral": <count>, "negative": <count>},
+  "keywords": ["keyword1", "keyword2", ...]
+}
+
+Rules:
+- List 3-8 pros and 3-8 cons, derived from actual review content.
+- sentiment counts must add up to the total number of reviews.
+- keywords: top 5-10 most frequently mentioned product aspects.
+- Respond ONLY with valid JSON. No markdown, no explanation.`, langName)
+
+	return systemPrompt, language
+}
+
+// buildReviewPrompt renders the reviews into the user-turn text block sent
+// alongside the system prompt.
+func buildReviewPrompt(reviews []models.Review) string {
+	var sb strings.Builder
+	for i, r := range reviews {
+		sb.WriteString(fmt.Sprintf("Review %d (Rating: %.1f/5): %s\n", i+1, r.Rating, r.Text))
+	}
+	return sb.String()
+}
+
+// parseAnalysisJSON strips markdown code fences and repairs the common
+// "extra prose around the JSON" failure mode before unmarshaling into an
+// AnalysisResult.
+func parseAnalysisJSON(raw, language string) (*models.AnalysisResult, error) {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimPrefix(raw, "```json")
+	raw = strings.TrimPrefix(raw, "```")
+	raw = strings.TrimSuffix(raw, "```")
+	raw = strings.TrimSpace(raw)
+
+	var result models.AnalysisResult
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		// JSON repair: the model sometimes wraps the object in explanatory
+		// prose despite being told not to — fall back to the outermost
+		// brace pair before giving up.
+		if start, end := strings.IndexByte(raw, '{'), strings.LastIndexByte(raw, '}'); start >= 0 && end > start {
+			if repairErr := json.Unmarshal([]byte(raw[start:end+1]), &result); repairErr == nil {
+				result.Language = language
+				return &result, nil
+			}
+		}
+		return nil, fmt.Errorf("failed to parse AI response: %w", err)
+	}
+
+	result.Language = language
+	return &result, nil
+}
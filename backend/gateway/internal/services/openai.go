@@ -2,17 +2,20 @@ package services
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"strings"
 
 	openai "github.com/sashabaranov/go-openai"
 	"reviewpulse/gateway/internal/models"
 )
 
-// OpenAIService wraps the OpenAI API client.
-// The API key is loaded from the environment and NEVER exposed in responses or logs.
+// OpenAIService wraps the OpenAI API client and implements LLMProvider.
+// The API key is loaded from the environment and NEVER exposed in
+// responses or logs.
 type OpenAIService struct {
 	client *openai.Client
 }
@@ -24,68 +27,104 @@ func NewOpenAIService(apiKey string) *OpenAIService {
 	}
 }
 
-// AnalyzeReviews sends reviews to GPT-4o-mini and returns structured analysis.
-func (o *OpenAIService) AnalyzeReviews(ctx context.Context, reviews []models.Review, language string) (*models.AnalysisResult, error) {
-	langName, ok := models.SupportedLanguages[language]
-	if !ok {
-		langName = "English"
-		language = "en"
-	}
-
-	// Build review text block
-	var sb strings.Builder
-	for i, r := range reviews {
-		sb.WriteString(fmt.Sprintf("Review %d (Rating: %.1f/5): %s\n", i+1, r.Rating, r.Text))
-	}
-
-	systemPrompt := fmt.Sprintf(`You are a product review analyst. Respond ENTIRELY in %s.
-
-Analyze the customer reviews provided and return a JSON object with exactly this structure:
-{
-  "summary": "A 2-3 sentence overall summary of customer opinions",
-  "pros": ["pro 1", "pro 2", ...],
-  "cons": ["con 1", "con 2", ...],
-  "sentiment": {"positive": <count>, "neutral": <count>, "negative": <count>},
-  "keywords": ["keyword1", "keyword2", ...]
-}
-
-Rules:
-- List 3-8 pros and 3-8 cons, derived from actual review content.
-- sentiment counts must add up to the total number of reviews.
-- keywords: top 5-10 most frequently mentioned product aspects.
-- Respond ONLY with valid JSON. No markdown, no explanation.`, langName)
+// Analyze sends reviews to GPT-4o-mini and returns structured analysis.
+func (o *OpenAIService) Analyze(ctx context.Context, reviews []models.Review, language string) (*models.AnalysisResult, error) {
+	systemPrompt, language := buildAnalysisSystemPrompt(language)
 
 	resp, err := o.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
 		Model: "gpt-4o-mini",
 		Messages: []openai.ChatCompletionMessage{
 			{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
-			{Role: openai.ChatMessageRoleUser, Content: sb.String()},
+			{Role: openai.ChatMessageRoleUser, Content: buildReviewPrompt(reviews)},
 		},
 		Temperature: 0.3,
 	})
 	if err != nil {
 		// IMPORTANT: Never log the API key. Only log the error message.
 		log.Printf("[openai] API call failed: %v", err)
-		return nil, fmt.Errorf("openai analysis failed: %w", err)
+		return nil, wrapOpenAIError(err)
 	}
 
 	if len(resp.Choices) == 0 {
 		return nil, fmt.Errorf("openai returned no choices")
 	}
 
-	raw := strings.TrimSpace(resp.Choices[0].Message.Content)
-	// Strip markdown code fences if present
-	raw = strings.TrimPrefix(raw, "```json")
-	raw = strings.TrimPrefix(raw, "```")
-	raw = strings.TrimSuffix(raw, "```")
-	raw = strings.TrimSpace(raw)
-
-	var result models.AnalysisResult
-	if err := json.Unmarshal([]byte(raw), &result); err != nil {
-		log.Printf("[openai] failed to parse response JSON: %v â€” raw: %s", err, raw)
-		return nil, fmt.Errorf("failed to parse AI response: %w", err)
+	return parseAnalysisJSON(resp.Choices[0].Message.Content, language)
+}
+
+// AnalyzeStream does the same as Analyze, but consumes OpenAI's streaming
+// chat completion API and forwards each token delta as a ProgressEvent so
+// the frontend sees incremental output rather than a single "complete" flip.
+func (o *OpenAIService) AnalyzeStream(ctx context.Context, reviews []models.Review, language string) (<-chan models.ProgressEvent, <-chan *models.AnalysisResult, error) {
+	systemPrompt, language := buildAnalysisSystemPrompt(language)
+
+	stream, err := o.client.CreateChatCompletionStream(ctx, openai.ChatCompletionRequest{
+		Model: "gpt-4o-mini",
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: buildReviewPrompt(reviews)},
+		},
+		Temperature: 0.3,
+	})
+	if err != nil {
+		log.Printf("[openai] stream create failed: %v", err)
+		return nil, nil, wrapOpenAIError(err)
 	}
 
-	result.Language = language
-	return &result, nil
+	events := make(chan models.ProgressEvent, 16)
+	results := make(chan *models.AnalysisResult, 1)
+
+	go func() {
+		defer stream.Close()
+		defer close(events)
+		defer close(results)
+
+		var raw strings.Builder
+		for {
+			chunk, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				log.Printf("[openai] stream recv error: %v", err)
+				return
+			}
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+			delta := chunk.Choices[0].Delta.Content
+			if delta == "" {
+				continue
+			}
+			raw.WriteString(delta)
+			events <- models.ProgressEvent{Status: "analyzing", Chunk: delta}
+		}
+
+		result, err := parseAnalysisJSON(raw.String(), language)
+		if err != nil {
+			log.Printf("[openai] failed to parse streamed response: %v", err)
+			return
+		}
+		results <- result
+	}()
+
+	return events, results, nil
+}
+
+// wrapOpenAIError extracts the HTTP status code from go-openai's error
+// type (when present) so CompositeProvider can decide whether to retry.
+func wrapOpenAIError(err error) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return &ProviderError{
+			Provider:   "openai",
+			StatusCode: apiErr.HTTPStatusCode,
+			Err:        fmt.Errorf("openai analysis failed: %w", err),
+		}
+	}
+	statusCode := 0
+	if errors.Is(err, context.DeadlineExceeded) {
+		statusCode = http.StatusGatewayTimeout
+	}
+	return &ProviderError{Provider: "openai", StatusCode: statusCode, Err: fmt.Errorf("openai analysis failed: %w", err)}
 }
@@ -16,6 +16,31 @@ const (
 	ScrapeResultQueue = "scrape_results"
 )
 
+// Queue is the backend-agnostic interface Handler and the result worker in
+// main.go depend on. It's implemented by QueueService (RabbitMQ) and
+// RedisQueue, selected at startup via config.Config.QueueBackend.
+type Queue interface {
+	PublishScrapeJob(ctx context.Context, job *models.ScrapeJob) error
+	ConsumeScrapeResults(ctx context.Context) (<-chan ScrapeResultDelivery, error)
+	// ConsumeScrapeResultsBatch is like ConsumeScrapeResults but delivers
+	// up to batchSize results per channel receive, trading a little
+	// latency for throughput under high result volume.
+	ConsumeScrapeResultsBatch(ctx context.Context, batchSize int) (<-chan []ScrapeResultDelivery, error)
+	Ping() error
+	Close()
+}
+
+// ScrapeResultDelivery pairs a decoded ScrapeResult with the Ack callback
+// that actually removes it from the backend's at-least-once-delivery
+// tracking (RabbitMQ's unacked set, Redis's processing list). Ack must not
+// be called until the consumer has finished — or durably handed off —
+// processing the result; acking right after decode would let a crash
+// mid-pipeline silently drop it with no trace in either backend.
+type ScrapeResultDelivery struct {
+	Result models.ScrapeResult
+	Ack    func()
+}
+
 // QueueService manages RabbitMQ connections and publishing/consuming.
 type QueueService struct {
 	conn    *amqp.Connection
@@ -62,15 +87,16 @@ func (q *QueueService) PublishScrapeJob(ctx context.Context, job *models.ScrapeJ
 	})
 }
 
-// ConsumeScrapeResults returns a channel of ScrapeResult messages.
-// It runs until the context is cancelled.
-func (q *QueueService) ConsumeScrapeResults(ctx context.Context) (<-chan models.ScrapeResult, error) {
+// ConsumeScrapeResults returns a channel of decoded ScrapeResult messages,
+// each paired with the Ack that actually removes it from RabbitMQ's unacked
+// set. It runs until the context is cancelled.
+func (q *QueueService) ConsumeScrapeResults(ctx context.Context) (<-chan ScrapeResultDelivery, error) {
 	msgs, err := q.channel.Consume(ScrapeResultQueue, "", false, false, false, false, nil)
 	if err != nil {
 		return nil, fmt.Errorf("consume %s: %w", ScrapeResultQueue, err)
 	}
 
-	results := make(chan models.ScrapeResult, 10)
+	results := make(chan ScrapeResultDelivery, 10)
 
 	go func() {
 		defer close(results)
@@ -88,8 +114,11 @@ func (q *QueueService) ConsumeScrapeResults(ctx context.Context) (<-chan models.
 					msg.Nack(false, false)
 					continue
 				}
-				msg.Ack(false)
-				results <- result
+				delivery := msg
+				results <- ScrapeResultDelivery{
+					Result: result,
+					Ack:    func() { delivery.Ack(false) },
+				}
 			}
 		}
 	}()
@@ -97,6 +126,59 @@ func (q *QueueService) ConsumeScrapeResults(ctx context.Context) (<-chan models.
 	return results, nil
 }
 
+// ConsumeScrapeResultsBatch drains up to batchSize results per emitted
+// slice, built on top of ConsumeScrapeResults.
+func (q *QueueService) ConsumeScrapeResultsBatch(ctx context.Context, batchSize int) (<-chan []ScrapeResultDelivery, error) {
+	results, err := q.ConsumeScrapeResults(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return batchResults(ctx, results, batchSize), nil
+}
+
+// batchResults accumulates up to batchSize items from in before emitting a
+// slice, flushing early on a short idle tick so a slow trickle of results
+// doesn't wait indefinitely for a full batch.
+func batchResults(ctx context.Context, in <-chan ScrapeResultDelivery, batchSize int) <-chan []ScrapeResultDelivery {
+	out := make(chan []ScrapeResultDelivery, 4)
+
+	go func() {
+		defer close(out)
+		batch := make([]ScrapeResultDelivery, 0, batchSize)
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			out <- batch
+			batch = make([]ScrapeResultDelivery, 0, batchSize)
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				flush()
+				return
+			case result, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+				batch = append(batch, result)
+				if len(batch) >= batchSize {
+					flush()
+				}
+			case <-ticker.C:
+				flush()
+			}
+		}
+	}()
+
+	return out
+}
+
 // Ping checks if RabbitMQ is reachable.
 func (q *QueueService) Ping() error {
 	if q.conn.IsClosed() {
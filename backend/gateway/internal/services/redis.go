@@ -2,39 +2,90 @@ package services
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-// RedisClient wraps the go-redis client with helper methods.
+// RedisClient wraps a go-redis UniversalClient with helper methods. The
+// UniversalClient interface is satisfied by *redis.Client, *redis.FailoverClient
+// (Sentinel) and *redis.ClusterClient alike, so every caller (SessionService,
+// the rate-limit middleware, etc.) works unchanged regardless of topology.
 type RedisClient struct {
-	client *redis.Client
+	client redis.UniversalClient
 	ttl    time.Duration
 }
 
-// NewRedisClient creates a connected Redis client.
-func NewRedisClient(redisURL string, password string, ttlHours int) (*RedisClient, error) {
-	var opts *redis.Options
+// RedisMode selects the Redis topology NewRedisClient connects to.
+const (
+	RedisModeSingle   = "single"
+	RedisModeSentinel = "sentinel"
+	RedisModeCluster  = "cluster"
+)
 
-	if redisURL != "" {
-		var err error
-		opts, err = redis.ParseURL(redisURL)
-		if err != nil {
-			return nil, fmt.Errorf("invalid redis URL: %w", err)
+// RedisConnectOptions carries the Sentinel/Cluster parameters needed in
+// addition to the single-node redisURL, mirroring config.Config's fields.
+// SingleAddrs/DB/TLS are only populated when the caller built these
+// options via ParseConnString rather than the individual REDIS_* fields.
+type RedisConnectOptions struct {
+	Mode          string
+	SentinelAddrs []string
+	MasterName    string
+	SentinelPass  string
+	ClusterAddrs  []string
+
+	SingleAddrs []string
+	DB          int
+	TLS         bool
+}
+
+// NewRedisClient creates a connected Redis client. When opts.Mode is
+// "sentinel" or "cluster" it builds a redis.NewFailoverClient or
+// redis.NewClusterClient respectively; otherwise it falls back to the
+// single-node redisURL behaviour this package has always had.
+func NewRedisClient(redisURL string, password string, ttlHours int, opts RedisConnectOptions) (*RedisClient, error) {
+	var client redis.UniversalClient
+
+	var tlsConfig *tls.Config
+	if opts.TLS {
+		tlsConfig = &tls.Config{}
+	}
+
+	switch opts.Mode {
+	case RedisModeSentinel:
+		if len(opts.SentinelAddrs) == 0 || opts.MasterName == "" {
+			return nil, fmt.Errorf("redis sentinel mode requires REDIS_SENTINEL_ADDRS and REDIS_MASTER_NAME")
 		}
-	} else {
-		opts = &redis.Options{
-			Addr:     "localhost:6379",
-			Password: password,
-			DB:       0,
+		client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       opts.MasterName,
+			SentinelAddrs:    opts.SentinelAddrs,
+			SentinelPassword: opts.SentinelPass,
+			Password:         password,
+			DB:               opts.DB,
+			TLSConfig:        tlsConfig,
+		})
+	case RedisModeCluster:
+		if len(opts.ClusterAddrs) == 0 {
+			return nil, fmt.Errorf("redis cluster mode requires REDIS_CLUSTER_ADDRS")
 		}
+		client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     opts.ClusterAddrs,
+			Password:  password,
+			TLSConfig: tlsConfig,
+		})
+	default:
+		singleOpts, err := singleNodeOptions(redisURL, password, opts)
+		if err != nil {
+			return nil, err
+		}
+		client = redis.NewClient(singleOpts)
 	}
 
-	client := redis.NewClient(opts)
-
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -48,6 +99,84 @@ func NewRedisClient(redisURL string, password string, ttlHours int) (*RedisClien
 	}, nil
 }
 
+func singleNodeOptions(redisURL, password string, opts RedisConnectOptions) (*redis.Options, error) {
+	if len(opts.SingleAddrs) > 0 {
+		var tlsConfig *tls.Config
+		if opts.TLS {
+			tlsConfig = &tls.Config{}
+		}
+		return &redis.Options{
+			Addr:      opts.SingleAddrs[0],
+			Password:  password,
+			DB:        opts.DB,
+			TLSConfig: tlsConfig,
+		}, nil
+	}
+	if redisURL != "" {
+		parsed, err := redis.ParseURL(redisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis URL: %w", err)
+		}
+		return parsed, nil
+	}
+	return &redis.Options{
+		Addr:     "localhost:6379",
+		Password: password,
+		DB:       0,
+	}, nil
+}
+
+// ParseConnString parses a unified connection string of the form
+// "addrs=host1:6379,host2:6379 db=0 sentinel_master=mymaster password=... tls=true"
+// into RedisConnectOptions plus the password and db it carries. It's an
+// alternative to setting REDIS_MODE/REDIS_SENTINEL_ADDRS/REDIS_CLUSTER_ADDRS
+// individually: presence of sentinel_master selects sentinel mode, more
+// than one addr with no sentinel_master selects cluster mode, and a single
+// addr falls back to single-node mode.
+func ParseConnString(s string) (opts RedisConnectOptions, password string, err error) {
+	var addrs []string
+
+	for _, field := range strings.Fields(s) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return RedisConnectOptions{}, "", fmt.Errorf("invalid redis connection string segment %q", field)
+		}
+		switch key {
+		case "addrs":
+			addrs = strings.Split(value, ",")
+		case "db":
+			db, err := strconv.Atoi(value)
+			if err != nil {
+				return RedisConnectOptions{}, "", fmt.Errorf("invalid db %q: %w", value, err)
+			}
+			opts.DB = db
+		case "sentinel_master":
+			opts.MasterName = value
+		case "password":
+			password = value
+		case "tls":
+			opts.TLS = value == "true"
+		default:
+			return RedisConnectOptions{}, "", fmt.Errorf("unknown redis connection string key %q", key)
+		}
+	}
+
+	switch {
+	case opts.MasterName != "":
+		opts.Mode = RedisModeSentinel
+		opts.SentinelAddrs = addrs
+	case len(addrs) > 1:
+		opts.Mode = RedisModeCluster
+		opts.ClusterAddrs = addrs
+	case len(addrs) == 1:
+		opts.Mode = RedisModeSingle
+		opts.SingleAddrs = addrs
+	default:
+		return RedisConnectOptions{}, "", fmt.Errorf("redis connection string must include addrs")
+	}
+	return opts, password, nil
+}
+
 // SetJSON stores a value as JSON with the session TTL.
 func (r *RedisClient) SetJSON(ctx context.Context, key string, value interface{}) error {
 	data, err := json.Marshal(value)
@@ -57,6 +186,17 @@ func (r *RedisClient) SetJSON(ctx context.Context, key string, value interface{}
 	return r.client.Set(ctx, key, data, r.ttl).Err()
 }
 
+// SetJSONWithTTL stores a value as JSON with a caller-supplied TTL instead
+// of the client's configured session TTL, for callers (like AnalysisCache)
+// that need their own expiry policy.
+func (r *RedisClient) SetJSONWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("marshal error: %w", err)
+	}
+	return r.client.Set(ctx, key, data, ttl).Err()
+}
+
 // GetJSON retrieves a JSON value and unmarshals it into dest.
 func (r *RedisClient) GetJSON(ctx context.Context, key string, dest interface{}) error {
 	data, err := r.client.Get(ctx, key).Bytes()
@@ -66,18 +206,43 @@ func (r *RedisClient) GetJSON(ctx context.Context, key string, dest interface{})
 	return json.Unmarshal(data, dest)
 }
 
-// RefreshTTL resets the TTL on all keys matching a prefix.
+// RefreshTTL resets the TTL on all keys matching a prefix. It uses SCAN
+// rather than KEYS so it doesn't block a shard, and — when the underlying
+// client is a *redis.ClusterClient — walks every master so keys hashed to
+// other shards are still found.
 func (r *RedisClient) RefreshTTL(ctx context.Context, prefix string) error {
-	keys, err := r.client.Keys(ctx, prefix+"*").Result()
-	if err != nil {
-		return err
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, shard *redis.Client) error {
+			return r.scanAndExpire(ctx, shard, prefix)
+		})
 	}
-	pipe := r.client.Pipeline()
-	for _, key := range keys {
-		pipe.Expire(ctx, key, r.ttl)
+	return r.scanAndExpire(ctx, r.client, prefix)
+}
+
+// scanAndExpire pages through keys matching prefix+"*" via SCAN and sets
+// the configured TTL on each one via a pipeline.
+func (r *RedisClient) scanAndExpire(ctx context.Context, client redis.Cmdable, prefix string) error {
+	var cursor uint64
+	match := prefix + "*"
+	for {
+		keys, next, err := client.Scan(ctx, cursor, match, 100).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			pipe := client.Pipeline()
+			for _, key := range keys {
+				pipe.Expire(ctx, key, r.ttl)
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				return err
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
 	}
-	_, err = pipe.Exec(ctx)
-	return err
 }
 
 // Delete removes a key.
@@ -101,6 +266,104 @@ func (r *RedisClient) Close() error {
 	return r.client.Close()
 }
 
+// SetBytes stores a raw byte slice with the given TTL (0 means no expiry).
+// Used for payloads that aren't JSON, such as a marshaled Bloom filter.
+func (r *RedisClient) SetBytes(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, data, ttl).Err()
+}
+
+// GetBytes retrieves a raw byte slice previously stored with SetBytes.
+func (r *RedisClient) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	return r.client.Get(ctx, key).Bytes()
+}
+
+// SetString stores a plain string value with the given TTL.
+func (r *RedisClient) SetString(ctx context.Context, key, value string, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+// GetString retrieves a plain string value previously stored with SetString.
+func (r *RedisClient) GetString(ctx context.Context, key string) (string, error) {
+	return r.client.Get(ctx, key).Result()
+}
+
+// AcquireLock takes a best-effort distributed lock using SET NX PX, returning
+// true if the lock was acquired. Callers must release it with ReleaseLock.
+func (r *RedisClient) AcquireLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, "1", ttl).Result()
+}
+
+// ReleaseLock releases a lock previously acquired with AcquireLock.
+func (r *RedisClient) ReleaseLock(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
+
+// RenewLock extends the TTL on a lock previously acquired with AcquireLock,
+// for a holder that's still working past the original TTL and needs to keep
+// other callers from acquiring it in the meantime.
+func (r *RedisClient) RenewLock(ctx context.Context, key string, ttl time.Duration) error {
+	return r.client.Expire(ctx, key, ttl).Err()
+}
+
+// ListPush pushes a raw payload onto the head of a Redis list (LPUSH).
+func (r *RedisClient) ListPush(ctx context.Context, key string, data []byte) error {
+	return r.client.LPush(ctx, key, data).Err()
+}
+
+// ListBRPopLPush blocks up to timeout for an element to appear on src, and
+// atomically moves it to dst, returning its payload. A zero timeout blocks
+// forever. This is the "reliable queue" primitive: dst acts as a processing
+// list so a consumer crash doesn't lose the in-flight message — it stays on
+// dst until ListRem acknowledges it.
+func (r *RedisClient) ListBRPopLPush(ctx context.Context, src, dst string, timeout time.Duration) ([]byte, error) {
+	return r.client.BRPopLPush(ctx, src, dst, timeout).Bytes()
+}
+
+// ListRem removes one occurrence of data from key (LREM key 1 data), used
+// to acknowledge a processing-list entry once it's been handled.
+func (r *RedisClient) ListRem(ctx context.Context, key string, data []byte) error {
+	return r.client.LRem(ctx, key, 1, data).Err()
+}
+
+// ListRange returns the raw payloads in key between start and stop
+// (inclusive, LRANGE semantics — use stop -1 for "to the end"), used by
+// the DLQ admin endpoint to list dead-lettered jobs.
+func (r *RedisClient) ListRange(ctx context.Context, key string, start, stop int64) ([][]byte, error) {
+	vals, err := r.client.LRange(ctx, key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]byte, len(vals))
+	for i, v := range vals {
+		out[i] = []byte(v)
+	}
+	return out, nil
+}
+
+// Publish sends a message on a Redis Pub/Sub channel.
+func (r *RedisClient) Publish(ctx context.Context, channel, message string) error {
+	return r.client.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe opens a Pub/Sub subscription to one or more channels. Callers
+// are responsible for closing the returned *redis.PubSub.
+func (r *RedisClient) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return r.client.Subscribe(ctx, channels...)
+}
+
+// PSubscribe opens a Pub/Sub subscription to one or more channel patterns.
+// Callers are responsible for closing the returned *redis.PubSub.
+func (r *RedisClient) PSubscribe(ctx context.Context, patterns ...string) *redis.PubSub {
+	return r.client.PSubscribe(ctx, patterns...)
+}
+
+// Eval runs a Lua script against Redis and returns its raw result. Used by
+// the rate-limit middleware to make sliding-window/token-bucket updates
+// atomic across the several commands each algorithm needs.
+func (r *RedisClient) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return r.client.Eval(ctx, script, keys, args...).Result()
+}
+
 // Incr increments a key (for rate limiting).
 func (r *RedisClient) Incr(ctx context.Context, key string, expiry time.Duration) (int64, error) {
 	count, err := r.client.Incr(ctx, key).Result()
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"reviewpulse/gateway/internal/models"
+)
+
+const (
+	redisScrapeJobsList       = "scrape_jobs"
+	redisScrapeResultsList    = "scrape_results"
+	redisScrapeResultsProcess = "scrape_results:processing"
+)
+
+// RedisQueue is a Queue backed by Redis lists, for deployments that already
+// run Redis and don't want to also operate RabbitMQ. It enqueues with
+// LPUSH and consumes with BRPOPLPUSH into a processing list, following the
+// standard Redis "reliable queue" pattern: a message only leaves the
+// processing list once the caller acks the ScrapeResultDelivery returned by
+// ConsumeScrapeResults, which should happen after processing, not decoding.
+type RedisQueue struct {
+	redis *RedisClient
+}
+
+// NewRedisQueue wraps an already-connected RedisClient as a Queue.
+func NewRedisQueue(redis *RedisClient) *RedisQueue {
+	return &RedisQueue{redis: redis}
+}
+
+// PublishScrapeJob enqueues a scrape job for the Python worker.
+func (q *RedisQueue) PublishScrapeJob(ctx context.Context, job *models.ScrapeJob) error {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return q.redis.ListPush(ctx, redisScrapeJobsList, body)
+}
+
+// ConsumeScrapeResults returns a channel of decoded ScrapeResult messages,
+// each paired with the Ack that removes it from the processing list. A
+// message only leaves the processing list once the caller calls Ack — not
+// merely once it's been decoded — so a consumer crash mid-pipeline (e.g.
+// between StoreReviews and StoreAnalysis) leaves it there rather than
+// silently dropping it. Entries left on the processing list are not moved
+// back onto the results list or redelivered. A message that fails to decode at
+// all can't be usefully retried, so that case is acked immediately.
+func (q *RedisQueue) ConsumeScrapeResults(ctx context.Context) (<-chan ScrapeResultDelivery, error) {
+	results := make(chan ScrapeResultDelivery, 10)
+
+	go func() {
+		defer close(results)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			body, err := q.redis.ListBRPopLPush(ctx, redisScrapeResultsList, redisScrapeResultsProcess, 5*time.Second)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				// Timeout with nothing to pop — poll again.
+				continue
+			}
+
+			var result models.ScrapeResult
+			if err := json.Unmarshal(body, &result); err != nil {
+				log.Printf("[redis_queue] failed to unmarshal scrape result: %v", err)
+				q.redis.ListRem(ctx, redisScrapeResultsProcess, body)
+				continue
+			}
+			processingBody := body
+			results <- ScrapeResultDelivery{
+				Result: result,
+				Ack:    func() { q.redis.ListRem(ctx, redisScrapeResultsProcess, processingBody) },
+			}
+		}
+	}()
+
+	return results, nil
+}
+
+// ConsumeScrapeResultsBatch drains up to batchSize results per emitted slice.
+func (q *RedisQueue) ConsumeScrapeResultsBatch(ctx context.Context, batchSize int) (<-chan []ScrapeResultDelivery, error) {
+	results, err := q.ConsumeScrapeResults(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return batchResults(ctx, results, batchSize), nil
+}
+
+// Ping checks Redis connectivity.
+func (q *RedisQueue) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := q.redis.Ping(ctx); err != nil {
+		return fmt.Errorf("redis queue ping: %w", err)
+	}
+	return nil
+}
+
+// Close is a no-op: the underlying RedisClient is owned and closed by main.go.
+func (q *RedisQueue) Close() {}
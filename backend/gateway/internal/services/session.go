@@ -2,10 +2,13 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 	"reviewpulse/gateway/internal/models"
 )
 
@@ -23,6 +26,46 @@ func sessionKey(token, suffix string) string {
 	return fmt.Sprintf("session:%s:%s", token, suffix)
 }
 
+func sessionEventsChannel(token string) string {
+	return fmt.Sprintf("session:%s:events", token)
+}
+
+// publishEvent broadcasts the current session state to subscribers on its
+// Pub/Sub channel, so an SSE-serving replica that isn't the one running the
+// scrape worker still finds out about status changes as they happen.
+// Failures are logged rather than returned — a missed publish just means a
+// connected client falls back to whatever it next reads from session:meta.
+func (s *SessionService) publishEvent(ctx context.Context, session *models.Session) {
+	s.publish(ctx, session.Token, &models.SessionEvent{Session: session})
+}
+
+// PublishChunk broadcasts a partial-token slice of the LLM's streamed
+// output, as produced by LLMProvider.AnalyzeStream, so a subscribed
+// StreamProgress connection can forward it to the frontend incrementally
+// instead of only learning about the analysis once it's complete.
+func (s *SessionService) PublishChunk(ctx context.Context, token, chunk string) {
+	s.publish(ctx, token, &models.SessionEvent{Chunk: chunk})
+}
+
+func (s *SessionService) publish(ctx context.Context, token string, event *models.SessionEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[session] marshal event for %s: %v", token, err)
+		return
+	}
+	if err := s.redis.Publish(ctx, sessionEventsChannel(token), string(data)); err != nil {
+		log.Printf("[session] publish event for %s: %v", token, err)
+	}
+}
+
+// Subscribe opens a Pub/Sub subscription to a session's events — both
+// status snapshots (UpdateStatus, SetError, StoreAnalysis) and streamed
+// chunks (PublishChunk), delivered as SessionEvent. Callers must close the
+// returned *redis.PubSub.
+func (s *SessionService) Subscribe(ctx context.Context, token string) *redis.PubSub {
+	return s.redis.Subscribe(ctx, sessionEventsChannel(token))
+}
+
 // Create initialises a new session and returns its token.
 func (s *SessionService) Create(ctx context.Context, url, platform, lang string) (*models.Session, error) {
 	token := uuid.New().String()
@@ -51,17 +94,23 @@ func (s *SessionService) Get(ctx context.Context, token string) (*models.Session
 	return &session, nil
 }
 
-// UpdateStatus changes the session status.
+// UpdateStatus changes the session status and publishes the change so any
+// subscribed StreamProgress connections pick it up immediately.
 func (s *SessionService) UpdateStatus(ctx context.Context, token, status string) error {
 	session, err := s.Get(ctx, token)
 	if err != nil {
 		return err
 	}
 	session.Status = status
-	return s.redis.SetJSON(ctx, sessionKey(token, "meta"), session)
+	if err := s.redis.SetJSON(ctx, sessionKey(token, "meta"), session); err != nil {
+		return err
+	}
+	s.publishEvent(ctx, session)
+	return nil
 }
 
-// SetError marks the session as errored with a message.
+// SetError marks the session as errored with a message and publishes the
+// change so any subscribed StreamProgress connections pick it up immediately.
 func (s *SessionService) SetError(ctx context.Context, token, msg string) error {
 	session, err := s.Get(ctx, token)
 	if err != nil {
@@ -69,7 +118,11 @@ func (s *SessionService) SetError(ctx context.Context, token, msg string) error
 	}
 	session.Status = "error"
 	session.ErrorMessage = msg
-	return s.redis.SetJSON(ctx, sessionKey(token, "meta"), session)
+	if err := s.redis.SetJSON(ctx, sessionKey(token, "meta"), session); err != nil {
+		return err
+	}
+	s.publishEvent(ctx, session)
+	return nil
 }
 
 // Heartbeat refreshes the TTL on all keys for a session.
@@ -77,6 +130,23 @@ func (s *SessionService) Heartbeat(ctx context.Context, token string) error {
 	return s.redis.RefreshTTL(ctx, fmt.Sprintf("session:%s:", token))
 }
 
+// SetDeadline records when a session's scrape+analyze pipeline must finish
+// by, so a reconnecting SSE client (StreamProgress) can derive a deadline
+// consistent with what it would have seen had it never disconnected,
+// instead of getting a fresh timeout budget.
+func (s *SessionService) SetDeadline(ctx context.Context, token string, deadline time.Time) error {
+	return s.redis.SetString(ctx, sessionKey(token, "deadline"), deadline.Format(time.RFC3339), time.Until(deadline))
+}
+
+// GetDeadline returns the deadline previously set with SetDeadline.
+func (s *SessionService) GetDeadline(ctx context.Context, token string) (time.Time, error) {
+	raw, err := s.redis.GetString(ctx, sessionKey(token, "deadline"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
 // StoreReviews saves scraped reviews.
 func (s *SessionService) StoreReviews(ctx context.Context, token string, reviews []models.Review) error {
 	return s.redis.SetJSON(ctx, sessionKey(token, "reviews"), reviews)
@@ -89,9 +159,17 @@ func (s *SessionService) GetReviews(ctx context.Context, token string) ([]models
 	return reviews, err
 }
 
-// StoreAnalysis saves AI analysis results.
+// StoreAnalysis saves AI analysis results and publishes the session state,
+// since the stored analysis is what GetSession/GetAnalysis serve once a
+// subscriber sees the status move to "complete".
 func (s *SessionService) StoreAnalysis(ctx context.Context, token string, result *models.AnalysisResult) error {
-	return s.redis.SetJSON(ctx, sessionKey(token, "analysis"), result)
+	if err := s.redis.SetJSON(ctx, sessionKey(token, "analysis"), result); err != nil {
+		return err
+	}
+	if session, err := s.Get(ctx, token); err == nil {
+		s.publishEvent(ctx, session)
+	}
+	return nil
 }
 
 // GetAnalysis retrieves AI analysis results.